@@ -6,12 +6,14 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"elasticsearch-alert/internal/alert"
 	"elasticsearch-alert/internal/config"
 	"elasticsearch-alert/internal/elasticsearch"
 	"elasticsearch-alert/internal/logging"
 	"elasticsearch-alert/internal/notification"
+	"elasticsearch-alert/internal/oncall"
 	"elasticsearch-alert/internal/web"
 )
 
@@ -42,9 +44,26 @@ func main() {
 	}
 	logging.Infof("Elasticsearch 客户端初始化完成，地址=%v", cfg.Elasticsearch.Addresses)
 
-	notifiers := notification.BuildNotifiers(cfg.Notifications)
+	notifiers, err := notification.BuildNotifiers(cfg.Notifications)
+	if err != nil {
+		log.Fatalf("初始化通知渠道失败: %v", err)
+	}
+	dispatcher, err := notification.NewDispatcher(notifiers, cfg.Notifications.Dispatch)
+	if err != nil {
+		log.Fatalf("初始化通知分发器失败: %v", err)
+	}
+	dispatcher.Start()
+
+	loc, err := time.LoadLocation(cfg.Scheduler.Timezone)
+	if err != nil {
+		loc = time.Local
+	}
+	schedules, err := oncall.New(cfg.OnCall, loc)
+	if err != nil {
+		log.Fatalf("加载值班表失败: %v", err)
+	}
 
-	engine, err := alert.NewEngine(cfg, esClient, notifiers)
+	engine, err := alert.NewEngine(cfg, esClient, dispatcher, schedules)
 	if err != nil {
 		log.Fatalf("初始化告警引擎失败: %v", err)
 	}
@@ -52,7 +71,7 @@ func main() {
 
 	// 启动内置 Web 服务，用于查看单条日志详情
 	if cfg.Web.Enabled {
-		webServer := web.NewServer(cfg, esClient)
+		webServer := web.NewServer(cfg, esClient, engine, schedules, dispatcher)
 		go func() {
 			if err := webServer.Start(); err != nil {
 				logging.Errorf("Web 服务异常退出: %v", err)
@@ -71,5 +90,6 @@ func main() {
 	<-signals
 
 	engine.Stop()
+	dispatcher.Stop()
 	logging.Infof("elasticsearch-alert 已停止")
 }
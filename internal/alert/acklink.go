@@ -0,0 +1,74 @@
+package alert
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"elasticsearch-alert/internal/logging"
+)
+
+// ackLinkSecret 返回签名确认链接的密钥：优先使用 web.ackSecret，未配置时随机生成
+func ackLinkSecret(configured string) []byte {
+	if configured != "" {
+		return []byte(configured)
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(fmt.Sprintf("generate ack secret: %v", err))
+	}
+	logging.Infof("未配置 web.ackSecret，已随机生成确认链接密钥，重启后旧的确认链接失效")
+	return secret
+}
+
+// ackSignature 对规则名称、告警指纹与过期时间计算 HMAC-SHA256
+func (e *Engine) ackSignature(rule, fingerprint string, expires int64) string {
+	mac := hmac.New(sha256.New, e.ackSecret)
+	fmt.Fprintf(mac, "%s\n%s\n%d", rule, fingerprint, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ackURL 生成带签名的确认页面链接，有效期为 web.ackLinkTTL
+func (e *Engine) ackURL(r Rule, now time.Time) string {
+	fp := r.Fingerprint()
+	expires := now.Add(e.cfg.Web.GetAckLinkTTL()).Unix()
+	q := url.Values{}
+	q.Set("rule", r.Name)
+	q.Set("fp", fp)
+	q.Set("exp", strconv.FormatInt(expires, 10))
+	q.Set("sig", e.ackSignature(r.Name, fp, expires))
+	return strings.TrimRight(e.cfg.Web.BaseURL, "/") + "/ack?" + q.Encode()
+}
+
+// VerifyAckLink 校验确认链接的签名与有效期，指纹需与规则当前的指纹一致（规则标签变更后旧链接失效）
+func (e *Engine) VerifyAckLink(rule, fingerprint, expires, sig string) error {
+	if rule == "" || fingerprint == "" || expires == "" || sig == "" {
+		return errors.New("缺少确认链接签名参数")
+	}
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return errors.New("确认链接无效")
+	}
+	if !hmac.Equal([]byte(sig), []byte(e.ackSignature(rule, fingerprint, exp))) {
+		return errors.New("确认链接签名无效")
+	}
+	if time.Now().Unix() > exp {
+		return errors.New("确认链接已过期")
+	}
+	for _, r := range e.rules {
+		if r.Name == rule {
+			if r.Fingerprint() != fingerprint {
+				return errors.New("确认链接与规则当前的告警不匹配")
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("规则 %s 不存在", rule)
+}
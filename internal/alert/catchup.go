@@ -0,0 +1,195 @@
+package alert
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"elasticsearch-alert/internal/config"
+	"elasticsearch-alert/internal/logging"
+	"elasticsearch-alert/internal/notification"
+	"elasticsearch-alert/internal/oncall"
+)
+
+// catchupMaxListed 汇总告警中最多逐条列出的时间窗个数
+const catchupMaxListed = 10
+
+// catchupResult 一个超过阈值的补查时间窗
+type catchupResult struct {
+	win     timeRange
+	count   int
+	samples []map[string]any
+}
+
+// catchupConfig 返回规则的补查配置：规则配置优先，其次为 rules.catchup
+func (e *Engine) catchupConfig(r Rule) config.CatchupConfig {
+	if r.Catchup != nil {
+		return *r.Catchup
+	}
+	return e.cfg.Rules.Catchup
+}
+
+// advanceWatermark 记录规则已评估到 end，水位只前进不后退
+func (e *Engine) advanceWatermark(r Rule, end time.Time) {
+	e.state.update(r.Name, func(st *ruleState) {
+		if end.After(st.Watermark) {
+			st.Watermark = end
+		}
+	})
+}
+
+// catchup 补查水位与本次时间窗之间错过的时间窗（进程停止、ES 不可用导致查询失败等），
+// 最多补查 maxCatchup。返回 false 表示补查查询失败，本次评估放弃，下次触发时从失败处继续补查。
+func (e *Engine) catchup(r Rule, win timeRange, now time.Time) bool {
+	cc := e.catchupConfig(r)
+	maxCatchup, _ := windowDuration(cc.MaxCatchup)
+	wm := e.state.get(r.Name).Watermark
+	// 首次运行没有水位；cron 周期不大于 timeWindow 时相邻时间窗首尾相接或重叠，没有遗漏
+	if maxCatchup <= 0 || wm.IsZero() || !win.Start.After(wm) {
+		return true
+	}
+	from := wm
+	if limit := win.Start.Add(-maxCatchup); from.Before(limit) {
+		logging.Infof("规则 %s 错过的时间超过 maxCatchup=%s，跳过 %s", r.Name, cc.MaxCatchup, e.formatRange(timeRange{Start: from, End: limit}))
+		from = limit
+	}
+	windows := splitWindows(from, win.Start, r)
+	logging.Infof("规则 %s 开始补查: %s 共 %d 个时间窗", r.Name, e.formatRange(timeRange{Start: from, End: win.Start}), len(windows))
+
+	var results []catchupResult
+	evaluated := 0
+	ok := true
+	for _, cw := range windows {
+		count, samples, err := e.queryCountAndSamples(r, cw)
+		if err != nil {
+			logging.Errorf("规则 %s 补查 %s 出错: %v", r.Name, e.formatWindow(r, cw), err)
+			ok = false
+			break
+		}
+		evaluated++
+		e.advanceWatermark(r, cw.End)
+		logging.Debugf("规则 %s 补查完成: 命中=%d 窗口=%s", r.Name, count, e.formatWindow(r, cw))
+		if e.hitThreshold(r, count) {
+			results = append(results, catchupResult{win: cw, count: count, samples: samples})
+		}
+	}
+	// 已补查的结果先发出，查询失败的时间窗留到下次
+	if len(results) > 0 {
+		logging.Infof("规则 %s 补查发现 %d 个时间窗超过阈值", r.Name, len(results))
+		if cc.Coalesce {
+			e.notifyCatchupSummary(r, results, evaluated, now)
+		} else {
+			for _, res := range results {
+				e.notifyCatchup(r, res, now)
+			}
+		}
+	}
+	return ok
+}
+
+// splitWindows 将 [from, to) 从 to 向前按规则的 timeWindow 切分，按时间先后返回；最早的一个时间窗可能不足 timeWindow
+func splitWindows(from, to time.Time, r Rule) []timeRange {
+	w, _ := windowDuration(r.TimeWindow)
+	var out []timeRange
+	for end := to; end.After(from); end = end.Add(-w) {
+		start := end.Add(-w)
+		if start.Before(from) {
+			start = from
+		}
+		out = append([]timeRange{{Start: start, End: end}}, out...)
+	}
+	return out
+}
+
+// notifyCatchup 发送单个补查时间窗的告警
+func (e *Engine) notifyCatchup(r Rule, res catchupResult, now time.Time) {
+	targets, shifts := e.resolveTargets(r, now)
+	title := fmt.Sprintf("[Elasticsearch Alert][补查] %s", r.Name)
+	summary := e.summarize(r, res.count, res.samples, res.win, shifts)
+	summary.Description = joinDescription("该时间窗在进程停止或 Elasticsearch 不可用期间未能评估，以下为恢复后的补查结果。", r.Description)
+	e.sendCatchup(r, title, summary, res.count, res.samples, res.win, now, targets, shifts)
+}
+
+// notifyCatchupSummary 将所有超过阈值的补查时间窗合并为一条汇总告警
+func (e *Engine) notifyCatchupSummary(r Rule, results []catchupResult, evaluated int, now time.Time) {
+	targets, shifts := e.resolveTargets(r, now)
+	total := 0
+	top := results[0]
+	for _, res := range results {
+		total += res.count
+		if res.count > top.count {
+			top = res
+		}
+	}
+	span := timeRange{Start: results[0].win.Start, End: results[len(results)-1].win.End}
+
+	var b strings.Builder
+	b.WriteString("以下时间窗在进程停止或 Elasticsearch 不可用期间未能评估，恢复后补查超过阈值：")
+	for i, res := range results {
+		if i == catchupMaxListed {
+			fmt.Fprintf(&b, "\n- …… 等共 %d 个时间窗", len(results))
+			break
+		}
+		fmt.Fprintf(&b, "\n- %s：命中 %d 条", e.formatWindow(r, res.win), res.count)
+	}
+
+	title := fmt.Sprintf("[Elasticsearch Alert][补查汇总] %s", r.Name)
+	// 样例取命中最多的时间窗
+	summary := e.summarize(r, total, top.samples, top.win, shifts)
+	summary.Description = joinDescription(b.String(), r.Description)
+	for i, f := range summary.Overview {
+		switch f.Name {
+		case "时间窗":
+			summary.Overview[i] = notification.Field{Name: "补查范围", Value: e.formatRange(span)}
+		case "命中条数":
+			summary.Overview[i] = notification.Field{Name: "命中条数", Value: fmt.Sprintf("%d（%d / %d 个时间窗超过阈值）", total, len(results), evaluated)}
+		}
+	}
+	summary.DiscoverURL = e.discoverURL(r, span.Start, span.End)
+	e.sendCatchup(r, title, summary, total, top.samples, span, now, targets, shifts)
+}
+
+// sendCatchup 将补查告警发送给规则的渠道。补查的时间窗已经过去，修复命令、呼叫值班人员等动作渠道
+// 默认不发送，只有列在 catchup.channels 中的动作渠道才会收到。补查告警不会再有对应的恢复，
+// 因此需要完整生命周期的渠道（PagerDuty / Opsgenie / Alertmanager、记录类渠道、飞书应用等）
+// 在 firing 之后紧接着收到 resolved，事件随即关闭。补查告警使用单独的指纹与 catchup 标签，
+// 与规则当前的告警及其他补查时间窗互不干扰，恢复时不会关闭正在进行的告警。
+func (e *Engine) sendCatchup(r Rule, title string, summary notification.Summary, count int, samples []map[string]any, win timeRange, now time.Time, targets []target, shifts []oncall.Shift) {
+	labels := make(map[string]string, len(r.Labels)+1)
+	for k, v := range r.Labels {
+		labels[k] = v
+	}
+	labels["catchup"] = win.Start.Format(time.RFC3339)
+	r.Labels = labels
+
+	st := &activeAlert{
+		startsAt:    win.Start,
+		fingerprint: fmt.Sprintf("%s-catchup-%d", r.Fingerprint(), win.Start.Unix()),
+		notified:    make(map[string]bool),
+		firstWindow: win,
+		window:      win,
+	}
+	optIn := e.catchupConfig(r).Channels
+	include := func(ch string) bool {
+		return !e.dispatcher.TakesAction(ch) || slices.Contains(optIn, ch)
+	}
+	e.dispatch(r, st, notification.StatusFiring, title, summary, count, samples, win, now, targets, include)
+
+	st.endsAt = win.End
+	resolved := e.summarizeLifecycle(r, st, notification.StatusResolved, count, win, shifts)
+	resolved.Description = summary.Description
+	include = func(ch string) bool {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		return st.notified[ch] && e.dispatcher.WantsResolved(ch)
+	}
+	e.dispatch(r, st, notification.StatusResolved, strings.Replace(title, "] ", "][已恢复] ", 1), resolved, count, nil, win, now, targets, include)
+}
+
+func joinDescription(prefix, desc string) string {
+	if desc == "" {
+		return prefix
+	}
+	return prefix + "\n\n" + desc
+}
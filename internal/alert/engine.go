@@ -2,19 +2,21 @@ package alert
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/robfig/cron/v3"
 
 	"elasticsearch-alert/internal/config"
+	"elasticsearch-alert/internal/discover"
 	eswrap "elasticsearch-alert/internal/elasticsearch"
 	"elasticsearch-alert/internal/logging"
 	"elasticsearch-alert/internal/notification"
+	"elasticsearch-alert/internal/oncall"
 	"os"
 	"path/filepath"
 
@@ -22,17 +24,45 @@ import (
 )
 
 type Engine struct {
-	cfg       *config.Config
-	es        *eswrap.Client
-	notifiers []notification.Notifier
+	cfg        *config.Config
+	es         *eswrap.Client
+	dispatcher *notification.Dispatcher
+	oncall     *oncall.Resolver
 
 	cron     *cron.Cron
 	location *time.Location
 
 	rules        []Rule
+	mu           sync.Mutex
 	lastAlertAt  map[string]time.Time
+	active       map[string]*activeAlert
 	defaultQuiet time.Duration
 	sampleSize   int
+
+	// state 各规则已评估到的时间（水位），用于补查错过的时间窗
+	state *stateStore
+
+	// ackSecret 签名确认链接的密钥
+	ackSecret []byte
+
+	// pits 增量模式下留给下次评估继续翻页的 PIT（本次新文档超过 maxDocs 时）
+	pits map[string]string
+
+	// running 各规则的评估锁，同一规则的评估逐个进行
+	running map[string]*sync.Mutex
+}
+
+// activeAlert 记录规则当前的告警状态，用于恢复检测
+type activeAlert struct {
+	startsAt    time.Time
+	endsAt      time.Time
+	fingerprint string
+	count       int       // 最近一次评估的命中条数
+	ackedAt     time.Time // 确认时间，未确认时为零值
+	ackedBy     string
+	notified    map[string]bool // 本次告警中已通知过的渠道
+	firstWindow timeRange       // 首次触发时的查询时间窗
+	window      timeRange       // 最近一次评估的查询时间窗
 }
 
 // Rules 返回当前加载的所有规则（只读使用）
@@ -40,7 +70,7 @@ func (e *Engine) Rules() []Rule {
 	return e.rules
 }
 
-func NewEngine(cfg *config.Config, es *eswrap.Client, notifiers []notification.Notifier) (*Engine, error) {
+func NewEngine(cfg *config.Config, es *eswrap.Client, dispatcher *notification.Dispatcher, schedules *oncall.Resolver) (*Engine, error) {
 	loc, err := time.LoadLocation(cfg.Scheduler.Timezone)
 	if err != nil {
 		loc = time.Local
@@ -50,12 +80,20 @@ func NewEngine(cfg *config.Config, es *eswrap.Client, notifiers []notification.N
 	engine := &Engine{
 		cfg:          cfg,
 		es:           es,
-		notifiers:    notifiers,
+		dispatcher:   dispatcher,
+		oncall:       schedules,
 		cron:         c,
 		location:     loc,
 		lastAlertAt:  make(map[string]time.Time),
+		active:       make(map[string]*activeAlert),
 		defaultQuiet: cfg.Rules.GetDefaultQuietPeriod(),
 		sampleSize:   cfg.Rules.SampleSize,
+		state:        newStateStore(cfg.Rules.StateFile),
+		pits:         make(map[string]string),
+		running:      make(map[string]*sync.Mutex),
+	}
+	if cfg.Web.Enabled {
+		engine.ackSecret = ackLinkSecret(cfg.Web.AckSecret)
 	}
 	if err := engine.loadRules(cfg.Rules.Directory); err != nil {
 		return nil, err
@@ -66,11 +104,21 @@ func NewEngine(cfg *config.Config, es *eswrap.Client, notifiers []notification.N
 func (e *Engine) Start() error {
 	for i := range e.rules {
 		r := e.rules[i]
-		_, err := e.cron.AddFunc(r.Cron, func() { e.executeRule(r) })
+		var id cron.EntryID
+		id, err := e.cron.AddFunc(r.Cron, func() {
+			// Entry.Prev 是本次运行的计划时间，查询时间窗以它为准而不是实际开始执行的时间
+			e.executeRule(r, e.cron.Entry(id).Prev)
+		})
 		if err != nil {
 			return fmt.Errorf("为规则 %q 添加定时任务失败: %w", r.Name, err)
 		}
-		logging.Infof("规则已注册: %s cron=%s 窗口=%s", r.Name, r.Cron, r.TimeWindow)
+		logging.Infof("规则已注册: %s cron=%s 窗口=%s 延迟=%s 时间字段=%s 模式=%s", r.Name, r.Cron, r.TimeWindow, r.Delay, e.timestampField(r), r.GetMode())
+		// 时间窗对齐到 timeWindow：周期更长时中间的时间窗没有评估（可开启补查），周期更短时同一时间窗会被重复评估
+		if w, _ := windowDuration(r.TimeWindow); r.GetMode() == ModeWindow {
+			if interval := e.evalInterval(r, time.Now()); interval != w {
+				logging.Infof("规则 %s 的 cron 周期 %s 与 timeWindow %s 不一致，建议保持一致", r.Name, interval, r.TimeWindow)
+			}
+		}
 	}
 	e.cron.Start()
 	return nil
@@ -103,32 +151,71 @@ func (e *Engine) loadRules(dir string) error {
 		if r.Name == "" || r.Index == "" || r.Cron == "" || r.TimeWindow == "" {
 			return fmt.Errorf("invalid rule %s: name/index/cron/timeWindow required", path)
 		}
+		if w, ok := windowDuration(r.TimeWindow); !ok || w <= 0 {
+			return fmt.Errorf("invalid rule %s: bad timeWindow %q", path, r.TimeWindow)
+		}
+		if _, ok := windowDuration(r.Delay); !ok {
+			return fmt.Errorf("invalid rule %s: bad delay %q", path, r.Delay)
+		}
+		if r.Mode != "" && r.Mode != ModeWindow && r.Mode != ModeIncremental {
+			return fmt.Errorf("invalid rule %s: unknown mode %q", path, r.Mode)
+		}
+		if r.Catchup != nil {
+			if _, ok := windowDuration(r.Catchup.MaxCatchup); !ok {
+				return fmt.Errorf("invalid rule %s: bad catchup.maxCatchup %q", path, r.Catchup.MaxCatchup)
+			}
+		}
 		rules = append(rules, r)
 	}
+	if _, ok := windowDuration(e.cfg.Rules.Catchup.MaxCatchup); !ok {
+		return fmt.Errorf("invalid rules.catchup.maxCatchup %q", e.cfg.Rules.Catchup.MaxCatchup)
+	}
 	e.rules = rules
 	return nil
 }
 
-func (e *Engine) executeRule(r Rule) {
+// executeRule 评估规则在计划时间 tick 对应的时间窗，tick 为零值时使用当前时间
+func (e *Engine) executeRule(r Rule, tick time.Time) {
 	defer func() {
 		if rec := recover(); rec != nil {
 			logging.Errorf("规则 %s 执行发生 panic: %v", r.Name, rec)
 		}
 	}()
-	now := time.Now().In(e.location)
-	logging.Debugf("规则定时触发: %s 时间=%s", r.Name, now.Format("2006-01-02 15:04:05"))
+	// 上次评估（查询慢或补查较多）还没结束时等待其完成：水位、游标与告警状态都在评估结束时更新，
+	// 同一规则并发评估会重复统计同一批文档
+	lock := e.ruleLock(r.Name)
+	lock.Lock()
+	defer lock.Unlock()
 
-	if !e.shouldFire(r, now) {
-		logging.Debugf("规则 %s 跳过执行（静默期内）", r.Name)
-		return
+	now := time.Now().In(e.location)
+	if tick.IsZero() {
+		tick = now
+	}
+	win := e.evalWindow(r, tick)
+	logging.Debugf("规则定时触发: %s 计划时间=%s 时间=%s", r.Name, tick.In(e.location).Format("2006-01-02 15:04:05"), now.Format("2006-01-02 15:04:05"))
+
+	// 静默期内仍然查询，以便及时发现恢复；静默期只抑制重复通知
+	var (
+		count   int
+		samples []map[string]any
+		err     error
+	)
+	if r.Mode == ModeIncremental {
+		// 增量模式的水位本身覆盖了错过的时间，不需要逐个时间窗补查；win 更新为实际查询的范围
+		count, samples, win, err = e.queryIncremental(r, win)
+	} else {
+		// 先补查错过的时间窗；补查失败时不评估本次时间窗，水位保持不变，下次触发时一并补查
+		if !e.catchup(r, win, now) {
+			return
+		}
+		count, samples, err = e.queryCountAndSamples(r, win)
 	}
-
-	count, samples, err := e.queryCountAndSamples(r)
 	if err != nil {
 		logging.Errorf("规则 %s 查询出错: %v", r.Name, err)
 		return
 	}
-	logging.Debugf("规则 %s 查询完成: 命中=%d 窗口=%s", r.Name, count, r.TimeWindow)
+	e.advanceWatermark(r, win.End)
+	logging.Debugf("规则 %s 查询完成: 命中=%d 窗口=%s", r.Name, count, e.formatWindow(r, win))
 
 	if !e.hitThreshold(r, count) {
 		if r.Threshold.CountGt != nil {
@@ -136,26 +223,231 @@ func (e *Engine) executeRule(r Rule) {
 		} else {
 			logging.Debugf("规则 %s 未触发: 未配置阈值 命中=%d", r.Name, count)
 		}
+		e.resolve(r, count, now, win)
+		return
+	}
+
+	e.mu.Lock()
+	st, firing := e.active[r.Name]
+	if !firing {
+		st = &activeAlert{startsAt: now, fingerprint: r.Fingerprint(), notified: make(map[string]bool), firstWindow: win}
+		e.active[r.Name] = st
+	}
+	st.count = count
+	st.window = win
+	// 已确认的告警在恢复前不再重复通知
+	fire := st.ackedAt.IsZero() && e.shouldFire(r, now)
+	if fire {
+		e.lastAlertAt[r.Name] = now
+	}
+	e.mu.Unlock()
+
+	// 静默期内只有新开始的告警会通知需要完整生命周期的渠道（如 PagerDuty），保证其能收到对应的 resolve；
+	// 要求持续推送的渠道（如 Alertmanager）每次评估都会收到通知
+	include := func(ch string) bool {
+		return fire || (!firing && e.dispatcher.WantsResolved(ch)) || e.dispatcher.WantsRepeat(ch)
+	}
+	if fire {
+		logging.Infof("规则 %s 触发告警: 命中=%d 通知渠道=%v", r.Name, count, r.Alerts.Channels)
+	} else {
+		logging.Debugf("规则 %s 持续告警中，静默期内或已确认，不重复通知", r.Name)
+	}
+	e.notify(r, st, notification.StatusFiring, count, samples, win, now, include)
+}
+
+// ruleLock 返回规则的评估锁
+func (e *Engine) ruleLock(rule string) *sync.Mutex {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	l, ok := e.running[rule]
+	if !ok {
+		l = &sync.Mutex{}
+		e.running[rule] = l
+	}
+	return l
+}
+
+// resolve 在规则不再满足阈值时结束告警，并向本次告警中通知过、且需要恢复通知的渠道发送 resolved
+func (e *Engine) resolve(r Rule, count int, now time.Time, win timeRange) {
+	e.mu.Lock()
+	st, firing := e.active[r.Name]
+	delete(e.active, r.Name)
+	if firing {
+		st.window = win
+	}
+	e.mu.Unlock()
+	if !firing {
 		return
 	}
-	logging.Infof("规则 %s 触发告警: 命中=%d 通知渠道=%v", r.Name, count, r.Alerts.Channels)
+	logging.Infof("规则 %s 已恢复: 命中=%d 持续=%s", r.Name, count, now.Sub(st.startsAt).Round(time.Second))
+	st.endsAt = now
+	include := func(ch string) bool {
+		return st.notified[ch] && (r.Alerts.SendResolved || e.dispatcher.WantsResolved(ch))
+	}
+	e.notify(r, st, notification.StatusResolved, count, nil, win, now, include)
+}
+
+// Ack 确认规则当前进行中的告警：之后直到恢复都不再重复通知，
+// 并向本次告警中通知过、且需要确认事件的渠道（如 CloudEvents 输出）发送 acked。
+func (e *Engine) Ack(rule, by string) error {
+	var r *Rule
+	for i := range e.rules {
+		if e.rules[i].Name == rule {
+			r = &e.rules[i]
+			break
+		}
+	}
+	if r == nil {
+		return fmt.Errorf("规则 %s 不存在", rule)
+	}
+	now := time.Now().In(e.location)
+	e.mu.Lock()
+	st, firing := e.active[rule]
+	if !firing {
+		e.mu.Unlock()
+		return fmt.Errorf("规则 %s 当前没有进行中的告警", rule)
+	}
+	if !st.ackedAt.IsZero() {
+		e.mu.Unlock()
+		return nil
+	}
+	st.ackedAt = now
+	st.ackedBy = by
+	count := st.count
+	win := st.window
+	e.mu.Unlock()
+
+	logging.Infof("规则 %s 的告警已被确认: 确认人=%s", rule, by)
+	include := func(ch string) bool {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		return st.notified[ch] && e.dispatcher.WantsAcked(ch)
+	}
+	e.notify(*r, st, notification.StatusAcked, count, nil, win, now, include)
+	return nil
+}
 
+// notify 渲染告警并放入各渠道的发送队列，include 用于筛选本次需要通知的渠道
+func (e *Engine) notify(r Rule, st *activeAlert, status string, count int, samples []map[string]any, win timeRange, now time.Time, include func(ch string) bool) {
+	targets, shifts := e.resolveTargets(r, now)
 	title := fmt.Sprintf("[Elasticsearch Alert] %s", r.Name)
-	body := e.renderBody(r, count, samples)
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	summary := e.summarize(r, count, samples, win, shifts)
+	switch status {
+	case notification.StatusResolved:
+		title = fmt.Sprintf("[Elasticsearch Alert][已恢复] %s", r.Name)
+		summary = e.summarizeLifecycle(r, st, status, count, win, shifts)
+	case notification.StatusAcked:
+		title = fmt.Sprintf("[Elasticsearch Alert][已确认] %s", r.Name)
+		summary = e.summarizeLifecycle(r, st, status, count, win, shifts)
+	}
+	e.dispatch(r, st, status, title, summary, count, samples, win, now, targets, include)
+}
+
+// dispatch 将告警按目标渠道入队，记录已通知的渠道
+func (e *Engine) dispatch(r Rule, st *activeAlert, status, title string, summary notification.Summary, count int, samples []map[string]any, win timeRange, now time.Time, targets []target, include func(ch string) bool) {
+	body := notification.RenderMarkdown(status, summary, len(samples) > 0)
+	for _, t := range targets {
+		if !include(t.channel) {
+			continue
+		}
+		ev := &notification.AlertEvent{
+			Title:        title,
+			Text:         body,
+			Rule:         r.Name,
+			Description:  r.Description,
+			Index:        r.Index,
+			Severity:     r.GetSeverity(),
+			Status:       status,
+			Fingerprint:  st.fingerprint,
+			Labels:       r.Labels,
+			Count:        count,
+			Threshold:    r.Threshold.CountGt,
+			TimeWindow:   r.TimeWindow,
+			WindowStart:  win.Start,
+			WindowEnd:    win.End,
+			Samples:      samples,
+			EvaluatedAt:  now,
+			StartsAt:     st.startsAt,
+			EndsAt:       st.endsAt,
+			AckedAt:      st.ackedAt,
+			AckedBy:      st.ackedBy,
+			EvalInterval: e.evalInterval(r, now),
+			Recipients:   t.recipients,
+			Mentions:     r.Alerts.Mentions,
+			Summary:      summary,
+			SlackChannel: r.Alerts.SlackChannel,
+			Email:        r.Alerts.Email,
+		}
+		// 渠道只接收部分告警（如按级别过滤）时不入队，也不算作已通知，之后的恢复通知同样不会发送
+		if !e.dispatcher.Accepts(t.channel, ev) {
+			logging.Debugf("规则 %s 的告警不符合渠道 %s 的接收条件，跳过", r.Name, t.channel)
+			continue
+		}
+		// 实际发送（含失败重试）由分发器异步完成
+		if err := e.dispatcher.Enqueue(t.channel, ev); err != nil {
+			logging.Errorf("规则 %s 通知入队失败: %v", r.Name, err)
+			continue
+		}
+		e.mu.Lock()
+		st.notified[t.channel] = true
+		e.mu.Unlock()
+	}
+}
+
+// target 是一次告警实际要发送的渠道及该渠道需要额外送达的人员
+type target struct {
+	channel    string
+	recipients []notification.Recipient
+}
+
+// resolveTargets 将规则中的渠道展开为发送目标："oncall:<值班表>" 会解析出当前值班人员，
+// 并通过该值班表配置的渠道送达；同一渠道只发送一次。
+func (e *Engine) resolveTargets(r Rule, now time.Time) ([]target, []oncall.Shift) {
+	var targets []target
+	var shifts []oncall.Shift
+	index := make(map[string]int)
+	add := func(ch string, rcpt *notification.Recipient) {
+		i, ok := index[ch]
+		if !ok {
+			i = len(targets)
+			index[ch] = i
+			targets = append(targets, target{channel: ch})
+		}
+		if rcpt != nil {
+			targets[i].recipients = append(targets[i].recipients, *rcpt)
+		}
+	}
 	for _, ch := range r.Alerts.Channels {
-		for _, n := range e.notifiers {
-			if n.Name() == ch || (ch == "console" && n.Name() == "console") {
-				if err := n.Send(ctx, title, body); err != nil {
-					logging.Errorf("通过渠道 %s 发送告警失败: %v", n.Name(), err)
-				} else {
-					logging.Debugf("通过渠道 %s 发送告警成功", n.Name())
-				}
-			}
+		name, ok := strings.CutPrefix(ch, "oncall:")
+		if !ok {
+			add(ch, nil)
+			continue
+		}
+		if e.oncall == nil {
+			logging.Errorf("规则 %s 引用了值班表 %s，但未配置 oncall", r.Name, name)
+			continue
+		}
+		shift, err := e.oncall.Current(name, now)
+		if err != nil {
+			logging.Errorf("规则 %s 解析值班人员失败: %v", r.Name, err)
+			continue
+		}
+		shifts = append(shifts, shift)
+		p := shift.Person
+		rcpt := notification.Recipient{
+			Name:           p.Name,
+			Email:          p.Email,
+			Phone:          p.Phone,
+			DingTalkMobile: p.DingTalkMobile,
+			DingTalkUserID: p.DingTalkUserID,
+			FeishuUserID:   p.FeishuUserID,
+			WeChatUserID:   p.WeChatUserID,
+		}
+		for _, c := range e.oncall.Channels(name) {
+			add(c, &rcpt)
 		}
 	}
-	e.lastAlertAt[r.Name] = now
+	return targets, shifts
 }
 
 func (e *Engine) shouldFire(r Rule, now time.Time) bool {
@@ -174,120 +466,161 @@ func (e *Engine) hitThreshold(r Rule, count int) bool {
 	return false
 }
 
-func (e *Engine) renderBody(r Rule, count int, samples []map[string]any) string {
+// summarize 整理告警正文的结构化内容：描述、概览、本次告警目标、代表性错误日志与详细日志链接
+func (e *Engine) summarize(r Rule, count int, samples []map[string]any, win timeRange, shifts []oncall.Shift) notification.Summary {
 	now := time.Now().In(e.location)
-	severity := r.Severity
-	if severity == "" {
-		severity = "Medium"
-	}
-
-	var b strings.Builder
-	// 标题行（正文内部的视觉标题，各渠道外层也有标题）
-	b.WriteString("🚨 **Elasticsearch 日志告警**\n\n")
+	sum := notification.Summary{Description: r.Description, RunbookURL: r.Runbook}
 
-	if r.Description != "" {
-		b.WriteString(r.Description + "\n\n")
+	add := func(fields *[]notification.Field, name, value string) {
+		if value != "" {
+			*fields = append(*fields, notification.Field{Name: name, Value: value})
+		}
 	}
-
-	// 概览信息
-	b.WriteString("📊 **告警概览**\n")
-	b.WriteString(fmt.Sprintf("- **规则名称：** %s\n", r.Name))
-	b.WriteString(fmt.Sprintf("- **告警级别：** %s\n", severity))
-	b.WriteString(fmt.Sprintf("- **触发时间：** %s\n", now.Format("2006-01-02 15:04:05")))
-	b.WriteString(fmt.Sprintf("- **索引：** %s\n", r.Index))
-	b.WriteString(fmt.Sprintf("- **时间窗：** %s\n", r.TimeWindow))
-	b.WriteString(fmt.Sprintf("- **命中条数：** %d\n", count))
+	add(&sum.Overview, "规则名称", r.Name)
+	add(&sum.Overview, "告警级别", r.GetSeverity())
+	add(&sum.Overview, "触发时间", now.Format("2006-01-02 15:04:05"))
+	add(&sum.Overview, "索引", r.Index)
+	add(&sum.Overview, "时间窗", e.formatWindow(r, win))
+	add(&sum.Overview, "命中条数", fmt.Sprintf("%d", count))
 	if r.Threshold.CountGt != nil {
-		b.WriteString(fmt.Sprintf("- **阈值：** > %d 条\n", *r.Threshold.CountGt))
+		add(&sum.Overview, "阈值", fmt.Sprintf("> %d 条", *r.Threshold.CountGt))
 	}
 	if r.QueryString != "" {
-		b.WriteString(fmt.Sprintf("- **查询：** %s\n", r.QueryString))
+		add(&sum.Overview, "查询", r.QueryString)
 	} else if r.DSL != nil {
-		b.WriteString("- **查询：** DSL\n")
+		add(&sum.Overview, "查询", "DSL")
+	}
+	for _, sh := range shifts {
+		add(&sum.Overview, fmt.Sprintf("值班人员（%s）", sh.Schedule), sh.Person.Name)
+	}
+	sum.DiscoverURL = e.discoverURL(r, win.Start, win.End)
+	// 确认链接指向本服务的确认页面（GET 只展示页面，提交后才会确认），需要开启 Web 服务并配置 baseURL；
+	// 链接带有签名与过期时间，确认页面只接受本服务生成的链接
+	if e.cfg.Web.Enabled && e.cfg.Web.BaseURL != "" {
+		sum.AckURL = e.ackURL(r, now)
 	}
 
 	// 只展示一条代表性的样例，突出节点/Pod/镜像/错误日志
-	if len(samples) > 0 {
-		doc := samples[0]
-		ts, _ := doc["@timestamp"].(string)
-		indexName, _ := doc["_index"].(string)
-		docID, _ := doc["_id"].(string)
-		node, _ := doc["kubernetes_host"].(string)
-		ns, _ := doc["kubernetes_namespace_name"].(string)
-		pod, _ := doc["kubernetes_pod_name"].(string)
-		image, _ := doc["kubernetes_container_image"].(string)
-		msg, _ := doc["message"].(string)
-		truncated := false
-		if len(msg) > 800 {
-			truncated = true
-			msg = msg[:800] + "..."
+	if len(samples) == 0 {
+		return sum
+	}
+	doc := samples[0]
+	ts, _ := docField(doc, e.timestampField(r)).(string)
+	indexName, _ := doc["_index"].(string)
+	docID, _ := doc["_id"].(string)
+	node, _ := doc["kubernetes_host"].(string)
+	ns, _ := doc["kubernetes_namespace_name"].(string)
+	pod, _ := doc["kubernetes_pod_name"].(string)
+	image, _ := doc["kubernetes_container_image"].(string)
+	msg, _ := doc["message"].(string)
+	// 按字符截断，避免截断多字节字符；各渠道还会按自身的大小限制进一步收缩
+	if r := []rune(msg); len(r) > 800 {
+		sum.LogTruncated = true
+		msg = string(r[:800]) + "..."
+	}
+	sum.Log = msg
+
+	add(&sum.Target, "节点名称", node)
+	add(&sum.Target, "命名空间", ns)
+	add(&sum.Target, "Pod 名称", pod)
+	add(&sum.Target, "Pod 镜像", image)
+	add(&sum.Target, "日志时间", ts)
+
+	// 详细日志链接：优先指向本服务提供的 Web 页面，其次回退到直接访问 ES 的 _doc API
+	if indexName != "" && docID != "" {
+		if e.cfg.Web.BaseURL != "" {
+			base := strings.TrimRight(e.cfg.Web.BaseURL, "/")
+			sum.DetailURL = fmt.Sprintf("%s/logs?index=%s&id=%s",
+				base,
+				url.QueryEscape(indexName),
+				url.QueryEscape(docID),
+			)
+		} else if len(e.cfg.Elasticsearch.Addresses) > 0 {
+			base := e.cfg.Elasticsearch.Addresses[0]
+			base = strings.TrimRight(base, "/")
+			sum.DetailURL = fmt.Sprintf("%s/%s/_doc/%s?pretty", base, indexName, docID)
 		}
+	}
+	return sum
+}
 
-		b.WriteString("\n📌 **本次告警目标**\n")
-		if node != "" {
-			b.WriteString(fmt.Sprintf("- **节点名称：** %s\n", node))
-		}
-		if ns != "" {
-			b.WriteString(fmt.Sprintf("- **命名空间：** %s\n", ns))
-		}
-		if pod != "" {
-			b.WriteString(fmt.Sprintf("- **Pod 名称：** %s\n", pod))
-		}
-		if image != "" {
-			b.WriteString(fmt.Sprintf("- **Pod 镜像：** %s\n", image))
-		}
-		if ts != "" {
-			b.WriteString(fmt.Sprintf("- **日志时间：** %s\n", ts))
-		}
+// summarizeLifecycle 整理恢复 / 确认通知的结构化内容
+func (e *Engine) summarizeLifecycle(r Rule, st *activeAlert, status string, count int, win timeRange, shifts []oncall.Shift) notification.Summary {
+	sum := notification.Summary{Description: r.Description, RunbookURL: r.Runbook}
+	sum.Overview = []notification.Field{
+		{Name: "规则名称", Value: r.Name},
+		{Name: "告警级别", Value: r.GetSeverity()},
+		{Name: "开始时间", Value: st.startsAt.Format("2006-01-02 15:04:05")},
+	}
+	if status == notification.StatusResolved {
+		sum.Overview = append(sum.Overview,
+			notification.Field{Name: "恢复时间", Value: st.endsAt.Format("2006-01-02 15:04:05")},
+			notification.Field{Name: "持续时间", Value: st.endsAt.Sub(st.startsAt).Round(time.Second).String()},
+		)
+	}
+	if !st.ackedAt.IsZero() {
+		sum.Overview = append(sum.Overview,
+			notification.Field{Name: "确认时间", Value: st.ackedAt.Format("2006-01-02 15:04:05")},
+			notification.Field{Name: "确认人", Value: st.ackedBy},
+		)
+	}
+	sum.Overview = append(sum.Overview, []notification.Field{
+		{Name: "索引", Value: r.Index},
+		{Name: "时间窗", Value: e.formatWindow(r, win)},
+		{Name: "当前命中条数", Value: fmt.Sprintf("%d", count)},
+	}...)
+	if r.Threshold.CountGt != nil {
+		sum.Overview = append(sum.Overview, notification.Field{Name: "阈值", Value: fmt.Sprintf("> %d 条", *r.Threshold.CountGt)})
+	}
+	for _, sh := range shifts {
+		sum.Overview = append(sum.Overview, notification.Field{Name: fmt.Sprintf("值班人员（%s）", sh.Schedule), Value: sh.Person.Name})
+	}
+	// Discover 链接覆盖整个告警期间：从首次触发的时间窗起点到最近一次评估的时间窗终点
+	sum.DiscoverURL = e.discoverURL(r, st.firstWindow.Start, win.End)
+	return sum
+}
 
-		if msg != "" {
-			b.WriteString("\n🧾 **错误日志**\n")
-			b.WriteString(msg)
-			if truncated {
-				b.WriteString("\n...(日志内容较长，已截断显示)")
-			}
-			b.WriteString("\n")
-		}
+// discoverURL 生成规则在 [from, to) 内的 Discover 链接，未配置 discover.baseURL 时返回空
+func (e *Engine) discoverURL(r Rule, from, to time.Time) string {
+	dc := e.cfg.Discover
+	if dc.BaseURL == "" {
+		return ""
+	}
+	dataView := r.DataViewID
+	if dataView == "" {
+		dataView = dc.DataViewID
+	}
+	return discover.URL(dc.BaseURL, dc.Flavor, discover.Query{
+		DataViewID:  dataView,
+		QueryString: r.QueryString,
+		DSL:         r.DSL,
+		TimeField:   e.timestampField(r),
+		From:        from,
+		To:          to,
+	})
+}
 
-		// 详细日志链接：优先指向本服务提供的 Web 页面，其次回退到直接访问 ES 的 _doc API
-		if indexName != "" && docID != "" {
-			if e.cfg.Web.BaseURL != "" {
-				base := strings.TrimRight(e.cfg.Web.BaseURL, "/")
-				detailURL := fmt.Sprintf("%s/logs?index=%s&id=%s",
-					base,
-					url.QueryEscape(indexName),
-					url.QueryEscape(docID),
-				)
-				b.WriteString("\n🔗 **详细日志链接：** ")
-				b.WriteString(detailURL)
-				b.WriteString("\n")
-			} else if len(e.cfg.Elasticsearch.Addresses) > 0 {
-				base := e.cfg.Elasticsearch.Addresses[0]
-				base = strings.TrimRight(base, "/")
-				detailURL := fmt.Sprintf("%s/%s/_doc/%s?pretty", base, indexName, docID)
-				b.WriteString("\n🔗 **详细日志链接：** ")
-				b.WriteString(detailURL)
-				b.WriteString("\n")
-			}
+// ruleFilter 返回规则自身的查询条件：query_string 优先，其次为 DSL；都未配置时返回 nil
+func (e *Engine) ruleFilter(r Rule) any {
+	if r.QueryString != "" {
+		return map[string]any{
+			"query_string": map[string]any{
+				"query":            r.QueryString,
+				"default_operator": "AND",
+			},
 		}
 	}
-
-	// 方便在通知模版底部额外追加 @所有人，这里不直接处理 @ 文本
-	return b.String()
+	return r.DSL
 }
 
-func (e *Engine) queryCountAndSamples(r Rule) (int, []map[string]any, error) {
-	window := r.TimeWindow
-	if window == "" {
-		window = "5m"
-	}
-	rangeGte := fmt.Sprintf("now-%s", window)
-	rangeLt := "now"
+// queryCountAndSamples 查询规则在时间窗 [win.Start, win.End) 内的命中条数与样例
+func (e *Engine) queryCountAndSamples(r Rule, win timeRange) (int, []map[string]any, error) {
+	field := e.timestampField(r)
 
 	query := map[string]any{
 		"size": e.sampleSize,
 		"sort": []map[string]any{
-			{"@timestamp": map[string]any{"order": "desc"}},
+			{field: map[string]any{"order": "desc"}},
 		},
 		"track_total_hits": true,
 		"query": map[string]any{
@@ -295,9 +628,10 @@ func (e *Engine) queryCountAndSamples(r Rule) (int, []map[string]any, error) {
 				"filter": []any{
 					map[string]any{
 						"range": map[string]any{
-							"@timestamp": map[string]any{
-								"gte": rangeGte,
-								"lt":  rangeLt,
+							field: map[string]any{
+								"gte":    win.Start.UTC().Format(time.RFC3339Nano),
+								"lt":     win.End.UTC().Format(time.RFC3339Nano),
+								"format": "strict_date_optional_time",
 							},
 						},
 					},
@@ -306,18 +640,9 @@ func (e *Engine) queryCountAndSamples(r Rule) (int, []map[string]any, error) {
 		},
 	}
 	boolQuery := query["query"].(map[string]any)["bool"].(map[string]any)
-	filters := boolQuery["filter"].([]any)
-	if r.QueryString != "" {
-		filters = append(filters, map[string]any{
-			"query_string": map[string]any{
-				"query":            r.QueryString,
-				"default_operator": "AND",
-			},
-		})
-	} else if r.DSL != nil {
-		filters = append(filters, r.DSL)
+	if f := e.ruleFilter(r); f != nil {
+		boolQuery["filter"] = append(boolQuery["filter"].([]any), f)
 	}
-	boolQuery["filter"] = filters
 
 	var buf bytes.Buffer
 	_ = json.NewEncoder(&buf).Encode(query)
@@ -0,0 +1,135 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"elasticsearch-alert/internal/config"
+	eswrap "elasticsearch-alert/internal/elasticsearch"
+)
+
+// fakeIndex 模拟 Elasticsearch 的增量查询（不使用 PIT），记录每条文档被返回的次数
+type fakeIndex struct {
+	docs []fakeDoc
+
+	mu       sync.Mutex
+	returned map[string]int
+}
+
+type fakeDoc struct {
+	id string
+	ts int64
+}
+
+func (f *fakeIndex) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Size  int `json:"size"`
+		Query struct {
+			Bool struct {
+				Filter []struct {
+					Range map[string]struct {
+						Gte int64 `json:"gte"`
+						Lt  int64 `json:"lt"`
+					} `json:"range"`
+				} `json:"filter"`
+				MustNot []struct {
+					Bool struct {
+						Filter []struct {
+							IDs struct {
+								Values []string `json:"values"`
+							} `json:"ids"`
+						} `json:"filter"`
+					} `json:"bool"`
+				} `json:"must_not"`
+			} `json:"bool"`
+		} `json:"query"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rng := req.Query.Bool.Filter[0].Range["@timestamp"]
+	excluded := make(map[string]bool)
+	for _, m := range req.Query.Bool.MustNot {
+		for _, id := range m.Bool.Filter[len(m.Bool.Filter)-1].IDs.Values {
+			excluded[id] = true
+		}
+	}
+	// 放慢查询，让两次评估重叠
+	time.Sleep(20 * time.Millisecond)
+
+	var hits []map[string]any
+	f.mu.Lock()
+	for _, d := range f.docs {
+		if len(hits) == req.Size {
+			break
+		}
+		if d.ts < rng.Gte || d.ts >= rng.Lt || (d.ts == rng.Gte && excluded[d.id]) {
+			continue
+		}
+		f.returned[d.id]++
+		hits = append(hits, map[string]any{"_index": "logs", "_id": d.id, "_source": map[string]any{}, "sort": []any{d.ts}})
+	}
+	f.mu.Unlock()
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"hits": map[string]any{"hits": hits}})
+}
+
+func TestExecuteRuleSerializesRuns(t *testing.T) {
+	windowStart := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	idx := &fakeIndex{returned: make(map[string]int)}
+	for i := 0; i < 23; i++ {
+		// 每三条文档时间相同，翻页时需要按 _id 排除已处理的文档
+		idx.docs = append(idx.docs, fakeDoc{id: fmt.Sprintf("doc-%02d", i), ts: windowStart.Add(time.Duration(i/3) * time.Second).UnixMilli()})
+	}
+	sort.SliceStable(idx.docs, func(i, j int) bool { return idx.docs[i].ts < idx.docs[j].ts })
+	srv := httptest.NewServer(idx)
+	defer srv.Close()
+
+	es, err := eswrap.NewClient(config.ElasticsearchConfig{Addresses: []string{srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{}
+	cfg.Rules.Incremental = config.IncrementalConfig{PageSize: 5, DisablePIT: true}
+	e := &Engine{
+		cfg:         cfg,
+		es:          es,
+		location:    time.UTC,
+		lastAlertAt: make(map[string]time.Time),
+		active:      make(map[string]*activeAlert),
+		sampleSize:  1,
+		state:       newStateStore(""),
+		pits:        make(map[string]string),
+		running:     make(map[string]*sync.Mutex),
+	}
+	r := Rule{Name: "k8s-error", Index: "logs", Cron: "0 */5 * * * *", TimeWindow: "5m", Mode: ModeIncremental}
+
+	tick := windowStart.Add(5 * time.Minute)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.executeRule(r, tick)
+		}()
+	}
+	wg.Wait()
+
+	for _, d := range idx.docs {
+		if n := idx.returned[d.id]; n != 1 {
+			t.Errorf("document %s consumed %d times, want 1", d.id, n)
+		}
+	}
+	cur := e.state.get(r.Name).Cursor
+	if last := idx.docs[len(idx.docs)-1]; cur == nil || cur.Timestamp != last.ts {
+		t.Errorf("cursor = %+v, want timestamp %d", cur, last.ts)
+	}
+}
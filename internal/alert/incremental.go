@@ -0,0 +1,287 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	eswrap "elasticsearch-alert/internal/elasticsearch"
+	"elasticsearch-alert/internal/logging"
+)
+
+// pitKeepAlive 每次翻页后 PIT 的最短保留时间，保留给下次评估的 PIT 还会加上规则的评估间隔
+const pitKeepAlive = time.Minute
+
+// maxCursorIDs 水位上最多记录的文档数。时间相同的文档超过该数量时（如时间字段只精确到秒且日志量很大），
+// 水位前移 1ms 并跳过该时间上剩余的文档，避免排除列表与状态文件无限增长
+const maxCursorIDs = 5000
+
+// incrementalCursor 增量模式的水位：最后处理的文档时间，以及该时间上已经处理过的文档。
+// 时间相同的文档以 _id 区分，下次查询从该时间开始并排除这些文档，既不重复也不遗漏。
+type incrementalCursor struct {
+	// Timestamp 时间字段的排序值（epoch 毫秒）
+	Timestamp int64 `json:"timestamp"`
+	// IDs 时间等于 Timestamp 的已处理文档
+	IDs []string `json:"ids,omitempty"`
+}
+
+// queryIncremental 增量模式：按时间升序逐页查询水位之后、本次时间窗终点之前的新文档，返回新文档条数、
+// 最新的样例与实际查询的时间范围。查询成功后水位前移并保存，每条文档最多参与一次告警。
+// 首次运行从本次时间窗起点开始；停止运行后最多回溯 maxCatchup（与补查配置相同），更早的文档跳过。
+// 水位只前进不后退：写入时时间已经早于水位的文档（采集延迟超过 delay）不会再被统计。
+func (e *Engine) queryIncremental(r Rule, win timeRange) (int, []map[string]any, timeRange, error) {
+	lookback, _ := windowDuration(e.catchupConfig(r).MaxCatchup)
+	floor := win.Start.Add(-lookback)
+	cur := incrementalCursor{Timestamp: floor.UnixMilli()}
+	if c := e.state.get(r.Name).Cursor; c != nil {
+		if c.Timestamp >= cur.Timestamp {
+			cur = *c
+		} else {
+			logging.Infof("规则 %s 增量水位早于可回溯的范围，跳过 %s", r.Name, e.formatRange(timeRange{Start: time.UnixMilli(c.Timestamp), End: floor}))
+		}
+	}
+	queried := timeRange{Start: time.UnixMilli(cur.Timestamp), End: win.End}
+	inc := e.cfg.Rules.Incremental
+
+	// PIT 保证翻页期间看到的是同一份数据；集群不支持时退化为普通查询。PIT 是打开时的快照，
+	// 看不到之后写入的文档，因此只有本次因 maxDocs 没有处理完时才保留给下次评估继续翻页，其余情况评估结束即关闭
+	keepAlive := fmt.Sprintf("%ds", int((pitKeepAlive + e.evalInterval(r, time.Now())).Seconds()))
+	pit, reused := "", false
+	if !inc.DisablePIT {
+		pit, reused = e.takePIT(r.Name), true
+		if pit == "" {
+			id, err := e.openPIT(r, keepAlive)
+			if err != nil {
+				return 0, nil, queried, err
+			}
+			pit, reused = id, false
+		}
+	}
+	backlog := false
+	defer func() {
+		if pit == "" {
+			return
+		}
+		if backlog {
+			e.keepPIT(r.Name, pit)
+			return
+		}
+		if err := e.es.ClosePIT(pit); err != nil {
+			logging.Debugf("规则 %s 关闭 PIT 失败: %v", r.Name, err)
+		}
+	}()
+
+	var (
+		count       int
+		samples     []map[string]any
+		start       = cur
+		searchAfter []any
+	)
+	for {
+		size := inc.GetPageSize()
+		if rest := inc.GetMaxDocs() - count; rest < size {
+			size = rest
+		}
+		hits, pitID, err := e.searchIncrementalPage(r, start, win.End, size, pit, keepAlive, searchAfter)
+		if err != nil && reused {
+			// 上次保留的 PIT 可能已经过期，重新打开后再查一次
+			logging.Debugf("规则 %s 保留的 PIT 不可用，重新打开: %v", r.Name, err)
+			reused = false
+			if pit, err = e.openPIT(r, keepAlive); err == nil {
+				hits, pitID, err = e.searchIncrementalPage(r, start, win.End, size, pit, keepAlive, searchAfter)
+			}
+		}
+		reused = false
+		if err != nil {
+			return 0, nil, queried, err
+		}
+		if pitID != "" {
+			pit = pitID
+		}
+		for _, h := range hits {
+			ts, err := sortTimestamp(h.Sort)
+			if err != nil {
+				return 0, nil, queried, fmt.Errorf("document %s/%s: %w", h.Index, h.ID, err)
+			}
+			if ts < cur.Timestamp {
+				// 水位已越过该时间（见 maxCursorIDs）
+				continue
+			}
+			if ts > cur.Timestamp {
+				cur = incrementalCursor{Timestamp: ts}
+			}
+			cur.IDs = append(cur.IDs, h.ID)
+			count++
+			if len(cur.IDs) >= maxCursorIDs {
+				logging.Infof("规则 %s 时间为 %s 的文档超过 %d 条，跳过该时间上剩余的文档", r.Name, time.UnixMilli(ts).In(e.location).Format("2006-01-02 15:04:05.000"), maxCursorIDs)
+				cur = incrementalCursor{Timestamp: ts + 1}
+			}
+
+			doc := h.Source
+			if doc == nil {
+				doc = make(map[string]any)
+			}
+			doc["_index"] = h.Index
+			doc["_id"] = h.ID
+			// 只保留最新的几条作为样例
+			samples = append(samples, doc)
+			if len(samples) > e.sampleSize {
+				samples = samples[1:]
+			}
+		}
+		if len(hits) < size {
+			break
+		}
+		if count >= inc.GetMaxDocs() {
+			logging.Infof("规则 %s 本次新文档超过 maxDocs=%d，其余文档留到下次评估", r.Name, inc.GetMaxDocs())
+			backlog = true
+			break
+		}
+		// 使用 PIT 时排序值带有 _shard_doc 等唯一的决胜值，可以直接 search_after；
+		// 否则时间相同的文档无法用 search_after 区分，改为从当前水位重新查询
+		if last := hits[len(hits)-1]; pit != "" && len(last.Sort) > 1 {
+			searchAfter = last.Sort
+		} else {
+			start, searchAfter = cur, nil
+		}
+	}
+
+	e.state.update(r.Name, func(st *ruleState) {
+		st.Cursor = &cur
+	})
+	// 样例按时间倒序，与时间窗模式一致
+	for i, j := 0, len(samples)-1; i < j; i, j = i+1, j-1 {
+		samples[i], samples[j] = samples[j], samples[i]
+	}
+	return count, samples, queried, nil
+}
+
+// openPIT 为规则的索引打开 PIT；集群不支持时返回空 ID，退化为普通查询
+func (e *Engine) openPIT(r Rule, keepAlive string) (string, error) {
+	id, err := e.es.OpenPIT(r.Index, keepAlive)
+	if errors.Is(err, eswrap.ErrPITUnsupported) {
+		logging.Debugf("规则 %s 不使用 PIT: %v", r.Name, err)
+		return "", nil
+	}
+	return id, err
+}
+
+// takePIT 取出上次评估保留的 PIT，没有时返回空
+func (e *Engine) takePIT(rule string) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	id := e.pits[rule]
+	delete(e.pits, rule)
+	return id
+}
+
+// keepPIT 保留 PIT 给下次评估继续翻页
+func (e *Engine) keepPIT(rule, id string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.pits[rule] = id
+}
+
+// incrementalHit 增量查询返回的文档
+type incrementalHit struct {
+	Index  string         `json:"_index"`
+	ID     string         `json:"_id"`
+	Source map[string]any `json:"_source"`
+	Sort   []any          `json:"sort"`
+}
+
+// searchIncrementalPage 查询一页水位 from 之后、end 之前的文档，返回文档与响应中最新的 PIT ID
+func (e *Engine) searchIncrementalPage(r Rule, from incrementalCursor, end time.Time, size int, pit, keepAlive string, searchAfter []any) ([]incrementalHit, string, error) {
+	field := e.timestampField(r)
+	filters := []any{
+		map[string]any{
+			"range": map[string]any{
+				field: map[string]any{
+					"gte":    from.Timestamp,
+					"lt":     end.UnixMilli(),
+					"format": "epoch_millis",
+				},
+			},
+		},
+	}
+	if f := e.ruleFilter(r); f != nil {
+		filters = append(filters, f)
+	}
+	boolQuery := map[string]any{"filter": filters}
+	if len(from.IDs) > 0 {
+		boolQuery["must_not"] = []any{
+			map[string]any{
+				"bool": map[string]any{
+					"filter": []any{
+						map[string]any{"range": map[string]any{field: map[string]any{"gte": from.Timestamp, "lte": from.Timestamp, "format": "epoch_millis"}}},
+						map[string]any{"ids": map[string]any{"values": from.IDs}},
+					},
+				},
+			},
+		}
+	}
+	query := map[string]any{
+		"size": size,
+		// numeric_type: date 使 date 与 date_nanos 字段的排序值都为毫秒
+		"sort": []any{
+			map[string]any{field: map[string]any{"order": "asc", "numeric_type": "date"}},
+		},
+		"query": map[string]any{"bool": boolQuery},
+	}
+	if searchAfter != nil {
+		query["search_after"] = searchAfter
+	}
+
+	var buf bytes.Buffer
+	var res *eswrap.Response
+	var err error
+	if pit != "" {
+		query["pit"] = map[string]any{"id": pit, "keep_alive": keepAlive}
+		_ = json.NewEncoder(&buf).Encode(query)
+		res, err = e.es.SearchPIT(&buf)
+	} else {
+		_ = json.NewEncoder(&buf).Encode(query)
+		res, err = e.es.Search(r.Index, &buf)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, "", fmt.Errorf("search error: %s", res.String())
+	}
+	var parsed struct {
+		PitID string `json:"pit_id"`
+		Hits  struct {
+			Hits []incrementalHit `json:"hits"`
+		} `json:"hits"`
+	}
+	// 排序值（含 _shard_doc）原样用于 search_after，需要保留数字精度
+	dec := json.NewDecoder(res.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&parsed); err != nil {
+		return nil, "", err
+	}
+	return parsed.Hits.Hits, parsed.PitID, nil
+}
+
+// sortTimestamp 读取排序值中的时间（epoch 毫秒）
+func sortTimestamp(sort []any) (int64, error) {
+	if len(sort) == 0 {
+		return 0, errors.New("missing sort values")
+	}
+	n, ok := sort[0].(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("unexpected sort value %v", sort[0])
+	}
+	if v, err := n.Int64(); err == nil {
+		return v, nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return 0, err
+	}
+	return int64(f), nil
+}
@@ -0,0 +1,76 @@
+package alert
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"elasticsearch-alert/internal/logging"
+)
+
+// ruleState 持久化的规则评估状态
+type ruleState struct {
+	// Watermark 已评估到的时间：该时间之前的时间窗都已成功查询
+	Watermark time.Time `json:"watermark"`
+	// Cursor 增量模式已处理到的位置
+	Cursor *incrementalCursor `json:"cursor,omitempty"`
+}
+
+// stateStore 保存各规则的评估状态，配置了 rules.stateFile 时每次更新都会写入文件，重启后恢复
+type stateStore struct {
+	file string
+
+	mu    sync.Mutex
+	rules map[string]ruleState
+}
+
+func newStateStore(file string) *stateStore {
+	s := &stateStore{file: file, rules: make(map[string]ruleState)}
+	if file == "" {
+		return s
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		logging.Errorf("创建规则评估状态目录失败: %v", err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logging.Errorf("读取规则评估状态失败: %v", err)
+		}
+		return s
+	}
+	if err := json.Unmarshal(data, &s.rules); err != nil {
+		logging.Errorf("解析规则评估状态失败: %v", err)
+	}
+	return s
+}
+
+func (s *stateStore) get(rule string) ruleState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rules[rule]
+}
+
+// update 修改规则的评估状态并保存
+func (s *stateStore) update(rule string, fn func(st *ruleState)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.rules[rule]
+	fn(&st)
+	s.rules[rule] = st
+	if s.file == "" {
+		return
+	}
+	data, _ := json.Marshal(s.rules)
+	// 先写临时文件再重命名，进程中途退出也不会留下不完整的状态文件
+	tmp := s.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		logging.Errorf("保存规则评估状态失败: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, s.file); err != nil {
+		logging.Errorf("保存规则评估状态失败: %v", err)
+	}
+}
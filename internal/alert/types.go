@@ -1,6 +1,14 @@
 package alert
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"elasticsearch-alert/internal/config"
+	"elasticsearch-alert/internal/notification"
+)
 
 type Threshold struct {
 	CountGt *int `yaml:"countGt"`
@@ -23,6 +31,15 @@ func (d Dedup) GetQuietPeriod(defaultVal time.Duration) time.Duration {
 
 type Alerts struct {
 	Channels []string `yaml:"channels"`
+	// Mentions 规则级别的 @ 提醒，会与各渠道配置中的提醒列表合并
+	Mentions notification.Mentions `yaml:"mentions"`
+	// SlackChannel 覆盖 Slack 渠道配置中的默认频道（仅 bot token 模式生效）
+	SlackChannel string `yaml:"slackChannel"`
+	// Email 规则级别的邮件收件人（收件人组或地址），默认追加到邮件渠道配置的收件人
+	Email *notification.EmailRecipients `yaml:"email"`
+	// SendResolved 为 true 时，规则恢复后也会向聊天类渠道发送恢复通知；
+	// PagerDuty 等需要完整生命周期的渠道总会收到恢复通知
+	SendResolved bool `yaml:"sendResolved"`
 }
 
 type Rule struct {
@@ -38,6 +55,58 @@ type Rule struct {
 	Alerts      Alerts    `yaml:"alerts"`
 	// Severity 用于展示在通知模板中（如 High / Medium / Low），不影响告警逻辑
 	Severity string `yaml:"severity"`
+	// Runbook 处理手册地址，支持的渠道会渲染为跳转按钮
+	Runbook string `yaml:"runbook"`
+	// DataViewID 覆盖 discover.dataViewId，规则查询的索引与默认数据视图不同时使用
+	DataViewID string `yaml:"dataViewId"`
+	// Labels 附加在告警上的标签，与规则名称一起决定告警的去重键（如 PagerDuty dedup_key）
+	Labels map[string]string `yaml:"labels"`
+	// Delay 为日志采集延迟预留的时间，如 "1m"：计划时间减去 delay 后再对齐到 timeWindow，timeWindow 为 5m 时，计划时间 10:06 的评估查询 [10:00, 10:05)
+	Delay string `yaml:"delay"`
+	// TimestampField 时间窗过滤与排序使用的时间字段，覆盖 rules.timestampField
+	TimestampField string `yaml:"timestampField"`
+	// Catchup 覆盖 rules.catchup
+	Catchup *config.CatchupConfig `yaml:"catchup"`
+	// Mode 评估方式：window（默认）按时间窗统计；incremental 只统计上次评估之后的新文档
+	Mode string `yaml:"mode"`
+}
+
+// 规则的评估方式
+const (
+	ModeWindow      = "window"
+	ModeIncremental = "incremental"
+)
+
+// GetMode 返回规则的评估方式，未配置时为 window
+func (r Rule) GetMode() string {
+	if r.Mode == "" {
+		return ModeWindow
+	}
+	return r.Mode
 }
 
+// GetSeverity 返回规则的告警级别，未配置时为 Medium
+func (r Rule) GetSeverity() string {
+	if r.Severity == "" {
+		return "Medium"
+	}
+	return r.Severity
+}
 
+// Fingerprint 由规则名称与标签计算出稳定的告警标识，用于下游平台去重（trigger / resolve 使用同一个值）。
+// 规则没有分组（group-by）查询，标签是静态配置，因此一条规则同一时间只有一个告警：
+// 不同主机、Pod 等命中的日志都会合并到同一个告警中，需要按维度区分时请拆分为多条规则并配置不同的标签。
+func (r Rule) Fingerprint() string {
+	keys := make([]string, 0, len(r.Labels))
+	for k := range r.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	h.Write([]byte(r.Name))
+	for _, k := range keys {
+		h.Write([]byte{0})
+		h.Write([]byte(k + "=" + r.Labels[k]))
+	}
+	return "esalert-" + hex.EncodeToString(h.Sum(nil))[:32]
+}
@@ -0,0 +1,115 @@
+package alert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// cronParser 与引擎使用的 cron.WithSeconds 解析格式一致
+var cronParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// timeRange 一次评估查询的时间窗 [Start, End)
+type timeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// evalWindow 按计划触发时间计算本次评估的绝对时间窗：tick-delay 向前对齐到 timeWindow 的整数倍作为终点，
+// 起点为终点 - timeWindow。使用计划时间而不是实际执行时间，并且对齐到时间窗，cron 抖动、触发延迟与
+// 查询耗时都不会造成窗口重叠或遗漏：相邻时间窗总是首尾相接。delay 为日志采集延迟预留时间。
+// 对齐按引擎时区计算，1d 的时间窗从当地零点开始。
+func (e *Engine) evalWindow(r Rule, tick time.Time) timeRange {
+	w, _ := windowDuration(r.TimeWindow)
+	delay, _ := windowDuration(r.Delay)
+	end := alignTime(tick.Add(-delay), w, e.location)
+	return timeRange{Start: end.Add(-w), End: end}
+}
+
+// alignTime 将 t 向前对齐到 loc 时区下 d 的整数倍
+func alignTime(t time.Time, d time.Duration, loc *time.Location) time.Time {
+	if d <= 0 {
+		return t.Truncate(time.Second)
+	}
+	_, offset := t.In(loc).Zone()
+	shift := time.Duration(offset) * time.Second
+	return t.Add(shift).Truncate(d).Add(-shift).In(t.Location())
+}
+
+// evalInterval 返回规则在 now 之后相邻两次计划评估的间隔，cron 表达式无效时返回 0
+func (e *Engine) evalInterval(r Rule, now time.Time) time.Duration {
+	sched, err := cronParser.Parse(r.Cron)
+	if err != nil {
+		return 0
+	}
+	next := sched.Next(now.In(e.location))
+	return sched.Next(next).Sub(next)
+}
+
+// timestampField 返回规则查询使用的时间字段：规则配置优先，其次为 rules.timestampField
+func (e *Engine) timestampField(r Rule) string {
+	if r.TimestampField != "" {
+		return r.TimestampField
+	}
+	return e.cfg.Rules.GetTimestampField()
+}
+
+// windowDuration 解析规则的时间窗与延迟，除 Go duration 外还支持 Elasticsearch 日期运算中的 d / w 单位（如 "1d"）。
+// 空字符串表示 0。
+func windowDuration(w string) (time.Duration, bool) {
+	if w == "" {
+		return 0, true
+	}
+	if d, err := time.ParseDuration(w); err == nil {
+		return d, d >= 0
+	}
+	if n, err := strconv.Atoi(w[:len(w)-1]); err == nil && n > 0 {
+		switch w[len(w)-1] {
+		case 'd':
+			return time.Duration(n) * 24 * time.Hour, true
+		case 'w':
+			return time.Duration(n) * 7 * 24 * time.Hour, true
+		}
+	}
+	return 0, false
+}
+
+// formatWindow 在通知中展示时间窗，如 "5m（2024-05-01 10:00:00 ~ 10:05:00）"，增量模式展示实际查询的范围
+func (e *Engine) formatWindow(r Rule, win timeRange) string {
+	if win.Start.IsZero() {
+		return r.TimeWindow
+	}
+	if r.Mode == ModeIncremental {
+		return fmt.Sprintf("增量（%s）", e.formatRange(win))
+	}
+	return fmt.Sprintf("%s（%s）", r.TimeWindow, e.formatRange(win))
+}
+
+// formatRange 展示时间范围，如 "2024-05-01 10:00:00 ~ 10:05:00"，同一天时结束时间省略日期
+func (e *Engine) formatRange(win timeRange) string {
+	start, end := win.Start.In(e.location), win.End.In(e.location)
+	endLayout := "2006-01-02 15:04:05"
+	if start.Format("2006-01-02") == end.Format("2006-01-02") {
+		endLayout = "15:04:05"
+	}
+	return start.Format("2006-01-02 15:04:05") + " ~ " + end.Format(endLayout)
+}
+
+// docField 读取文档中的字段，支持 "event.created" 这样的点分路径（先按扁平字段名查找）
+func docField(doc map[string]any, path string) any {
+	if v, ok := doc[path]; ok {
+		return v
+	}
+	var cur any = doc
+	for _, part := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		cur = m[part]
+	}
+	return cur
+}
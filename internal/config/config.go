@@ -15,6 +15,8 @@ type Config struct {
 	Notifications Notifications       `yaml:"notifications"`
 	Web           WebConfig           `yaml:"web"`
 	Logging       LoggingConfig       `yaml:"logging"`
+	OnCall        OnCallConfig        `yaml:"oncall"`
+	Discover      DiscoverConfig      `yaml:"discover"`
 }
 
 type ElasticsearchConfig struct {
@@ -48,6 +50,55 @@ type RulesConfig struct {
 	Directory          string `yaml:"directory"`
 	SampleSize         int    `yaml:"sampleSize"`
 	DefaultQuietPeriod string `yaml:"defaultQuietPeriod"`
+	// TimestampField 时间窗过滤与排序使用的时间字段，默认 @timestamp，规则可以通过 timestampField 覆盖
+	TimestampField string `yaml:"timestampField"`
+	// StateFile 保存各规则已评估到的时间（水位）的文件，为空时只保存在内存中，重启后无法补查
+	StateFile string `yaml:"stateFile"`
+	// Catchup 补查默认配置，规则可以通过 catchup 覆盖
+	Catchup CatchupConfig `yaml:"catchup"`
+	// Incremental 增量模式（规则 mode: incremental）的查询参数
+	Incremental IncrementalConfig `yaml:"incremental"`
+}
+
+// IncrementalConfig 增量模式：按水位只查询上次之后的新文档，每条文档最多参与一次告警
+type IncrementalConfig struct {
+	// PageSize 每页文档数，默认 1000
+	PageSize int `yaml:"pageSize"`
+	// MaxDocs 每次评估最多处理的文档数，默认 10000，超出部分留到下次评估
+	MaxDocs int `yaml:"maxDocs"`
+	// DisablePIT 为 true 时不使用 point in time（默认在支持的集群上使用）
+	DisablePIT bool `yaml:"disablePit"`
+}
+
+func (i IncrementalConfig) GetPageSize() int {
+	if i.PageSize <= 0 {
+		return 1000
+	}
+	return i.PageSize
+}
+
+func (i IncrementalConfig) GetMaxDocs() int {
+	if i.MaxDocs <= 0 {
+		return 10000
+	}
+	return i.MaxDocs
+}
+
+// CatchupConfig 补查：进程停止或 Elasticsearch 不可用期间错过的时间窗，在恢复后按水位补充评估
+type CatchupConfig struct {
+	// MaxCatchup 最多补查多长时间（如 "1h"），更早的时间窗直接跳过；为空或 0 时不补查
+	MaxCatchup string `yaml:"maxCatchup"`
+	// Coalesce 为 true 时所有补查的时间窗合并为一条汇总告警，避免重启后告警刷屏
+	Coalesce bool `yaml:"coalesce"`
+	// Channels 补查告警也要发送的动作渠道（exec、PagerDuty、Opsgenie 等），默认补查告警不发送到这些渠道
+	Channels []string `yaml:"channels"`
+}
+
+func (r RulesConfig) GetTimestampField() string {
+	if r.TimestampField == "" {
+		return "@timestamp"
+	}
+	return r.TimestampField
 }
 
 func (r RulesConfig) GetDefaultQuietPeriod() time.Duration {
@@ -62,11 +113,46 @@ func (r RulesConfig) GetDefaultQuietPeriod() time.Duration {
 }
 
 type Notifications struct {
-	Webhook  WebhookConfig  `yaml:"webhook"`
-	Feishu   FeishuConfig   `yaml:"feishu"`
-	DingTalk DingTalkConfig `yaml:"dingtalk"`
-	WeChat   WeChatConfig   `yaml:"wechat"`
-	Email    EmailConfig    `yaml:"email"`
+	Webhook      WebhookConfig      `yaml:"webhook"`
+	Feishu       FeishuConfig       `yaml:"feishu"`
+	Teams        TeamsConfig        `yaml:"teams"`
+	PagerDuty    PagerDutyConfig    `yaml:"pagerduty"`
+	Opsgenie     OpsgenieConfig     `yaml:"opsgenie"`
+	Alertmanager AlertmanagerConfig `yaml:"alertmanager"`
+	CloudEvents  CloudEventsConfig  `yaml:"cloudevents"`
+	Exec         ExecConfig         `yaml:"exec"`
+	Syslog       SyslogConfig       `yaml:"syslog"`
+	File         FileConfig         `yaml:"file"`
+	DingTalk     DingTalkConfig     `yaml:"dingtalk"`
+	WeChat       WeChatConfig       `yaml:"wechat"`
+	Email        EmailConfig        `yaml:"email"`
+	Slack        SlackConfig        `yaml:"slack"`
+	Telegram     TelegramConfig     `yaml:"telegram"`
+	Dispatch     DispatchConfig     `yaml:"dispatch"`
+}
+
+// DispatchConfig 控制通知发送队列：失败重试、退避与落盘
+type DispatchConfig struct {
+	// SpoolDir 为待发送通知与死信的落盘目录，为空时仅保存在内存中（重启后丢失）
+	SpoolDir       string `yaml:"spoolDir"`
+	MaxAttempts    int    `yaml:"maxAttempts"`    // 最大尝试次数，默认 8
+	InitialBackoff string `yaml:"initialBackoff"` // 首次重试等待，默认 5s
+	MaxBackoff     string `yaml:"maxBackoff"`     // 重试等待上限，默认 10m
+	SendTimeout    string `yaml:"sendTimeout"`    // 单次发送超时，默认 30s
+	DeadLetterSize int    `yaml:"deadLetterSize"` // 保留的死信条数，默认 1000
+	HistorySize    int    `yaml:"historySize"`    // 保留的发送历史条数，默认 1000
+	// RateLimits 按渠道名称配置限流，未配置的渠道使用内置默认值（钉钉 / 企业微信 20 条每分钟，飞书 100 条每分钟）
+	RateLimits map[string]RateLimitConfig `yaml:"rateLimits"`
+}
+
+// RateLimitConfig 渠道令牌桶限流配置
+type RateLimitConfig struct {
+	PerMinute int `yaml:"perMinute"` // 每分钟允许发送的条数，-1 表示不限流
+	Burst     int `yaml:"burst"`     // 桶容量，默认等于 perMinute
+	// Overflow 超出限流时的处理方式：queue（排队等待，默认）| summarize（积压过多时合并为一条汇总消息）
+	Overflow string `yaml:"overflow"`
+	// SummarizeAfter 积压达到该条数时才合并，默认 5
+	SummarizeAfter int `yaml:"summarizeAfter"`
 }
 
 // WebConfig 控制内置 HTTP Web 服务（查看单条日志详情）
@@ -74,6 +160,33 @@ type WebConfig struct {
 	Enabled bool   `yaml:"enabled"` // 是否开启 Web 服务
 	Listen  string `yaml:"listen"`  // 监听地址，如 ":8080"
 	BaseURL string `yaml:"baseURL"` // 对外访问的基础地址，用于在通知中生成跳转链接，如 "http://alert.example.com:8080"
+	// AckSecret 签名告警确认链接的密钥，同时作为 POST /api/alerts/ack、死信接口与 /oncall 联系方式的 Bearer Token；
+	// 未配置时启动时随机生成，重启后旧的确认链接失效，确认接口只接受签名链接，死信接口不可用
+	AckSecret string `yaml:"ackSecret"`
+	// AckLinkTTL 确认链接的有效期，默认 24h
+	AckLinkTTL string `yaml:"ackLinkTTL"`
+}
+
+func (w WebConfig) GetAckLinkTTL() time.Duration {
+	if w.AckLinkTTL == "" {
+		return 24 * time.Hour
+	}
+	d, err := time.ParseDuration(w.AckLinkTTL)
+	if err != nil {
+		return 24 * time.Hour
+	}
+	return d
+}
+
+// DiscoverConfig 配置后告警通知会带上 Kibana / OpenSearch Dashboards Discover 的跳转链接，
+// 链接中预置规则的查询条件与告警的时间窗
+type DiscoverConfig struct {
+	BaseURL string `yaml:"baseURL"` // 如 "https://kibana.example.com"，Kibana 非默认空间为 "https://kibana.example.com/s/<空间>"
+	// DataViewID Kibana 数据视图（索引模式）/ OpenSearch Dashboards 索引模式的 ID，规则可以通过 dataViewId 覆盖
+	DataViewID string `yaml:"dataViewId"`
+	// Flavor 链接格式：kibana（默认，也适用于旧版 OpenSearch Dashboards）| opensearch（OpenSearch Dashboards 2.10 及以后的新版 Discover）
+	// 不按 elasticsearch.provider 推断：对接 ES 7.x 时 provider 也可能是 opensearch
+	Flavor string `yaml:"flavor"`
 }
 
 // LoggingConfig 控制日志级别
@@ -82,43 +195,333 @@ type LoggingConfig struct {
 	Level string `yaml:"level"`
 }
 
+// OnCallConfig 定义值班人员与值班表，规则中可以通过 "oncall:<值班表名称>" 通知当前值班人员
+type OnCallConfig struct {
+	People    []OnCallPerson   `yaml:"people"`
+	Schedules []ScheduleConfig `yaml:"schedules"`
+}
+
+// OnCallPerson 值班人员及其在各渠道中的联系方式
+type OnCallPerson struct {
+	ID             string `yaml:"id" json:"id"`
+	Name           string `yaml:"name" json:"name"`
+	Email          string `yaml:"email" json:"email,omitempty"`
+	Phone          string `yaml:"phone" json:"phone,omitempty"`
+	DingTalkMobile string `yaml:"dingtalkMobile" json:"dingtalkMobile,omitempty"`
+	DingTalkUserID string `yaml:"dingtalkUserId" json:"dingtalkUserId,omitempty"` // 钉钉 userid，工作通知直接发送给值班人员
+	FeishuUserID   string `yaml:"feishuUserId" json:"feishuUserId,omitempty"`     // 飞书 open_id 或 user_id，用于 <at> 提醒
+	WeChatUserID   string `yaml:"wechatUserId" json:"wechatUserId,omitempty"`     // 企业微信 userid，应用消息模式下直接发送给值班人员
+}
+
+// ScheduleConfig 一张值班表，由若干轮换层与临时替班组成
+type ScheduleConfig struct {
+	Name     string `yaml:"name"`
+	Timezone string `yaml:"timezone"` // 为空时使用 scheduler.timezone
+	// Channels 为通知值班人员时使用的渠道，默认 ["email"]
+	Channels  []string              `yaml:"channels"`
+	Layers    []ScheduleLayerConfig `yaml:"layers"`
+	Overrides []ScheduleOverride    `yaml:"overrides"`
+}
+
+// ScheduleLayerConfig 轮换层：参与人员按顺序轮流值班，排在后面的层优先级更高
+type ScheduleLayerConfig struct {
+	Name         string   `yaml:"name"`
+	Start        string   `yaml:"start"`        // 轮换起始日期，如 "2024-01-01"，第一位人员从当天的交接时间开始值班
+	Rotation     string   `yaml:"rotation"`     // daily | weekly | Go duration（如 "72h"），默认 weekly
+	HandoffTime  string   `yaml:"handoffTime"`  // 交接时间，如 "10:00"，默认 "09:00"
+	Participants []string `yaml:"participants"` // 人员 ID，按顺序轮换
+	// ActiveHours 限定该层每天的生效时间段，如 "09:00-18:00"，为空表示全天
+	ActiveHours string `yaml:"activeHours"`
+	// Weekdays 限定该层生效的星期，如 ["mon","tue","wed","thu","fri"]，为空表示每天
+	Weekdays []string `yaml:"weekdays"`
+}
+
+// ScheduleOverride 临时替班，时间格式 "2006-01-02 15:04"（值班表时区）
+type ScheduleOverride struct {
+	Person string `yaml:"person"`
+	Start  string `yaml:"start"`
+	End    string `yaml:"end"`
+}
+
 type WebhookConfig struct {
 	URL     string            `yaml:"url"`
 	Headers map[string]string `yaml:"headers"`
 	Timeout string            `yaml:"timeout"`
+	// Method 请求方法，默认 POST
+	Method string `yaml:"method"`
+	// BodyTemplate Go 模板，为空时发送默认的 {"title","message","ts"} JSON
+	BodyTemplate string `yaml:"bodyTemplate"`
+	ContentType  string `yaml:"contentType"` // 默认 application/json
+	// Secret 非空时对 "<时间戳>.<请求体>" 做 HMAC-SHA256 签名，写入 SignatureHeader / TimestampHeader
+	Secret          string `yaml:"secret"`
+	SignatureHeader string `yaml:"signatureHeader"` // 默认 X-Signature
+	TimestampHeader string `yaml:"timestampHeader"` // 默认 X-Signature-Timestamp
+	Username        string `yaml:"username"`
+	Password        string `yaml:"password"`
+	BearerToken     string `yaml:"bearerToken"`
+	// SuccessCodes 视为发送成功的状态码，为空时 2xx 均视为成功
+	SuccessCodes []int `yaml:"successCodes"`
 }
 
 type FeishuConfig struct {
 	Webhook      string `yaml:"webhook"`
+	Secret       string `yaml:"secret"` // 群机器人启用“签名校验”时填写
 	EnableAtAll  bool   `yaml:"enableAtAll"`
 	Timeout      string `yaml:"timeout"`
 	TitlePrefix  string `yaml:"titlePrefix"`
 	ContentIntro string `yaml:"contentIntro"`
+	// AtUserIDs 每条告警都会 @ 的用户（open_id 或 user_id）
+	AtUserIDs []string `yaml:"atUserIds"`
+	// AtAllSeverities 非空时只有这些级别的告警才会 @所有人（覆盖 enableAtAll），如 ["Critical"]
+	AtAllSeverities []string `yaml:"atAllSeverities"`
+	// MaxBytes 卡片正文的大小预算（字节），默认 20000
+	MaxBytes int `yaml:"maxBytes"`
+	// App 配置 appId 后使用应用机器人发送（不再使用 webhook）
+	App FeishuAppConfig `yaml:"app"`
 }
 
-type DingTalkConfig struct {
+// FeishuAppConfig 飞书自建应用机器人：通过 tenant_access_token 调用 im/v1/messages 发送到群聊或用户，
+// 告警恢复 / 确认时原地更新卡片
+type FeishuAppConfig struct {
+	AppID     string           `yaml:"appId"`
+	AppSecret string           `yaml:"appSecret"`
+	APIURL    string           `yaml:"apiURL"` // 默认 https://open.feishu.cn，Lark 国际版为 https://open.larksuite.com
+	Receivers []FeishuReceiver `yaml:"receivers"`
+	// MessageStateFile 保存已发送卡片 message_id 的文件，为空时只保存在内存中（重启后恢复时无法更新卡片）
+	MessageStateFile string `yaml:"messageStateFile"`
+}
+
+// FeishuReceiver 消息接收方，Type 为 chat_id / open_id / user_id / union_id / email
+type FeishuReceiver struct {
+	Type string `yaml:"type"`
+	ID   string `yaml:"id"`
+}
+
+// TeamsConfig Microsoft Teams 的 Workflows / Incoming Webhook 地址，消息以 Adaptive Card 渲染
+type TeamsConfig struct {
 	Webhook     string `yaml:"webhook"`
-	Secret      string `yaml:"secret"`
-	EnableAtAll bool   `yaml:"enableAtAll"`
 	Timeout     string `yaml:"timeout"`
+	TitlePrefix string `yaml:"titlePrefix"`
+	MaxBytes    int    `yaml:"maxBytes"` // 卡片内容的大小预算（字节），默认 20000
+}
+
+// PagerDutyConfig PagerDuty Events API v2 配置
+type PagerDutyConfig struct {
+	RoutingKey string `yaml:"routingKey"` // 服务集成的 Integration Key
+	EventsURL  string `yaml:"eventsURL"`  // 默认 https://events.pagerduty.com/v2/enqueue
+	Source     string `yaml:"source"`     // payload.source，默认使用规则的索引
+	ClientURL  string `yaml:"clientURL"`  // PagerDuty 中展示的来源链接，可选
+	Timeout    string `yaml:"timeout"`
+}
+
+// OpsgenieConfig Opsgenie Alert API 配置
+type OpsgenieConfig struct {
+	APIKey     string              `yaml:"apiKey"` // API 集成的 GenieKey
+	APIURL     string              `yaml:"apiURL"` // 默认 https://api.opsgenie.com，EU 区域为 https://api.eu.opsgenie.com
+	Responders []OpsgenieResponder `yaml:"responders"`
+	Tags       []string            `yaml:"tags"`
+	Source     string              `yaml:"source"` // 默认使用规则的索引
+	Timeout    string              `yaml:"timeout"`
+}
+
+// OpsgenieResponder 告警的响应方，type 为 team / user / escalation / schedule，id / username / name 任选其一
+type OpsgenieResponder struct {
+	Type     string `yaml:"type"`
+	ID       string `yaml:"id"`
+	Name     string `yaml:"name"`
+	Username string `yaml:"username"`
+}
+
+// AlertmanagerConfig Prometheus Alertmanager v2 API 配置
+type AlertmanagerConfig struct {
+	// URLs Alertmanager 地址（如 http://alertmanager:9093），高可用集群需要列出全部实例
+	URLs        []string `yaml:"urls"`
+	Username    string   `yaml:"username"`
+	Password    string   `yaml:"password"`
+	BearerToken string   `yaml:"bearerToken"`
+	// ResolveTimeout 告警中 endsAt 距当前时间的最短间隔，实际取该值与规则评估间隔 3 倍中的较大者，默认 5m
+	ResolveTimeout string `yaml:"resolveTimeout"`
+	Timeout        string `yaml:"timeout"`
+}
+
+// CloudEventsConfig 以 CloudEvents 1.0 JSON 输出告警事件（firing / resolved / acked），
+// 可写入 Kafka（渠道 kafka）或本地 NDJSON 文件（渠道 eventfile）
+type CloudEventsConfig struct {
+	Source     string          `yaml:"source"`     // 事件 source，默认 /elasticsearch-alert
+	TypePrefix string          `yaml:"typePrefix"` // 事件 type 前缀，默认 com.elasticsearch-alert.alert，完整 type 如 <前缀>.firing
+	Kafka      KafkaConfig     `yaml:"kafka"`
+	File       EventFileConfig `yaml:"file"`
+}
+
+// KafkaConfig 通过 Kafka REST Proxy（v2 API）写入 Kafka，消息 key 为规则名称。
+// 仅支持 REST Proxy（如 Confluent REST Proxy），不支持直连 broker（bootstrap servers）。
+type KafkaConfig struct {
+	RestProxyURL string `yaml:"restProxyURL"` // REST Proxy 地址（不是 broker 地址），如 http://kafka-rest:8082
+	Topic        string `yaml:"topic"`
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	Timeout      string `yaml:"timeout"`
+}
+
+// EventFileConfig 将事件按行追加写入本地文件（NDJSON），便于测试与离线分析
+type EventFileConfig struct {
+	Path string `yaml:"path"`
+}
+
+// ExecConfig 告警触发时执行的命令（用于自动修复）。只有在 commands 中登记的命令才能被规则通过
+// "exec:<名称>" 引用，规则本身无法指定任意命令或参数。
+type ExecConfig struct {
+	Commands      map[string]ExecCommand `yaml:"commands"`
+	MaxConcurrent int                    `yaml:"maxConcurrent"` // 同时执行的命令数上限，默认 2
+}
+
+// ExecCommand 一条登记的命令，直接执行（不经过 shell）
+type ExecCommand struct {
+	Command []string          `yaml:"command"` // 可执行文件及参数，如 ["/opt/remediation/restart-consumer.sh"]
+	Dir     string            `yaml:"dir"`
+	Env     map[string]string `yaml:"env"`
+	Timeout string            `yaml:"timeout"` // 默认 1m
+	// OnResolved 为 true 时告警恢复也会执行（ALERT_STATUS=resolved），默认只在告警触发时执行
+	OnResolved bool `yaml:"onResolved"`
+}
+
+// SyslogConfig 以 RFC 5424 格式将告警写入 syslog（渠道 syslog），用于接入 SIEM。
+// 告警字段放在结构化数据（SD）中，MSG 为告警的 JSON 记录。
+type SyslogConfig struct {
+	Network  string `yaml:"network"`  // udp | tcp | tls | unix，默认 udp
+	Address  string `yaml:"address"`  // 如 siem.example.com:514；unix 为 socket 路径，默认 /dev/log
+	Facility string `yaml:"facility"` // 如 local0 / user / auth / security，默认 local0
+	AppName  string `yaml:"appName"`  // 默认 elasticsearch-alert
+	Hostname string `yaml:"hostname"` // 默认本机主机名
+	// SDID 结构化数据 ID，需为 name@<私有企业号>，默认 alert@32473（文档保留号）
+	SDID          string `yaml:"sdId"`
+	TLSCAFile     string `yaml:"tlsCAFile"`
+	TLSServerName string `yaml:"tlsServerName"`
+	TLSSkipVerify bool   `yaml:"tlsSkipVerify"`
+	Timeout       string `yaml:"timeout"`
+}
+
+// FileConfig 将告警记录按行追加写入本地 JSON Lines 文件（渠道 file），按大小轮转
+type FileConfig struct {
+	Path       string `yaml:"path"`
+	MaxSizeMB  int    `yaml:"maxSizeMB"`  // 单个文件上限，默认 100
+	MaxBackups int    `yaml:"maxBackups"` // 保留的历史文件数（<path>.1 ~ <path>.N），默认 5
+}
+
+type DingTalkConfig struct {
+	Webhook         string   `yaml:"webhook"`
+	Secret          string   `yaml:"secret"`
+	EnableAtAll     bool     `yaml:"enableAtAll"`
+	Timeout         string   `yaml:"timeout"`
+	AtMobiles       []string `yaml:"atMobiles"`
+	AtUserIDs       []string `yaml:"atUserIds"`
+	AtAllSeverities []string `yaml:"atAllSeverities"`
+	MaxBytes        int      `yaml:"maxBytes"` // 消息正文的大小预算（字节），默认 20000
+	// MessageType 消息类型：markdown（默认）| actionCard（带“查看日志详情”/ Ack / Runbook 按钮）
+	MessageType string `yaml:"messageType"`
+	// App 配置 appKey 后额外注册 dingtalk_work 渠道，以企业内部应用的工作通知发送给指定用户
+	App DingTalkAppConfig `yaml:"app"`
+}
+
+// DingTalkAppConfig 钉钉企业内部应用：通过 access_token 调用 asyncsend_v2 发送工作通知，
+// 不受群机器人免打扰影响，适合只对 Critical 等高级别告警使用
+type DingTalkAppConfig struct {
+	AppKey    string   `yaml:"appKey"`
+	AppSecret string   `yaml:"appSecret"`
+	AgentID   int64    `yaml:"agentId"`
+	UserIDs   []string `yaml:"userIds"`
+	DeptIDs   []string `yaml:"deptIds"`
+	// Severities 非空时只有这些级别的告警才发送工作通知，如 ["Critical"]
+	Severities []string `yaml:"severities"`
+	APIURL     string   `yaml:"apiURL"` // 默认 https://oapi.dingtalk.com
 }
 
 type WeChatConfig struct {
+	Webhook     string `yaml:"webhook"`
+	Timeout     string `yaml:"timeout"`
+	EnableAtAll bool   `yaml:"enableAtAll"`
+	// MentionedList 企业微信 userid 列表，MentionedMobileList 手机号列表
+	MentionedList       []string `yaml:"mentionedList"`
+	MentionedMobileList []string `yaml:"mentionedMobileList"`
+	AtAllSeverities     []string `yaml:"atAllSeverities"`
+	MaxBytes            int      `yaml:"maxBytes"` // markdown 消息的大小预算（字节），默认群机器人 4096、应用消息 2048
+	// MessageType 消息类型：markdown（默认）| template_card（文本通知卡片，带查看详情 / Runbook 跳转按钮）
+	MessageType string `yaml:"messageType"`
+	// App 配置 corpId 后使用应用消息发送给指定成员 / 部门 / 标签（不再使用群机器人 webhook）
+	App WeChatAppConfig `yaml:"app"`
+}
+
+// WeChatAppConfig 企业微信自建应用：通过 access_token 调用 message/send 发送应用消息
+type WeChatAppConfig struct {
+	CorpID  string   `yaml:"corpId"`
+	AgentID int      `yaml:"agentId"`
+	Secret  string   `yaml:"secret"`
+	ToUser  []string `yaml:"toUser"`  // 成员 userid，"@all" 表示应用可见范围内的全部成员
+	ToParty []string `yaml:"toParty"` // 部门 ID
+	ToTag   []string `yaml:"toTag"`   // 标签 ID
+	APIURL  string   `yaml:"apiURL"`  // 默认 https://qyapi.weixin.qq.com
+}
+
+// SlackConfig 支持两种模式：配置 webhook 使用 Incoming Webhook；配置 token 使用 chat.postMessage（支持按规则指定频道与消息串）
+type SlackConfig struct {
 	Webhook string `yaml:"webhook"`
+	Token   string `yaml:"token"`   // Bot User OAuth Token（xoxb-...）
+	Channel string `yaml:"channel"` // token 模式下的默认频道
+	APIURL  string `yaml:"apiURL"`  // 默认 https://slack.com/api
 	Timeout string `yaml:"timeout"`
+	// ThreadTTL 同一次告警的后续通知回复到首条消息的消息串中，消息串在该时间后过期，默认 24h，"0" 表示不使用消息串
+	ThreadTTL string `yaml:"threadTTL"`
+	// ThreadStateFile 保存消息串 ts 的文件，为空时只保存在内存中
+	ThreadStateFile string `yaml:"threadStateFile"`
+}
+
+// TelegramConfig Telegram Bot API sendMessage 配置
+type TelegramConfig struct {
+	BotToken  string         `yaml:"botToken"`
+	Chats     []TelegramChat `yaml:"chats"`
+	ParseMode string         `yaml:"parseMode"` // HTML（默认）| MarkdownV2
+	APIURL    string         `yaml:"apiURL"`    // 默认 https://api.telegram.org
+	ProxyURL  string         `yaml:"proxyURL"`  // 如 "http://proxy:3128"、"socks5://proxy:1080"
+	Timeout   string         `yaml:"timeout"`
+}
+
+// TelegramChat 一个接收告警的会话，ThreadID 用于发送到超级群组的某个话题
+type TelegramChat struct {
+	ChatID   string `yaml:"chatId"`
+	ThreadID int64  `yaml:"threadId"`
 }
 
 type EmailConfig struct {
-	Host          string   `yaml:"host"`
-	Port          int      `yaml:"port"`
-	Username      string   `yaml:"username"`
-	Password      string   `yaml:"password"`
-	From          string   `yaml:"from"`
-	To            []string `yaml:"to"`
-	UseTLS        bool     `yaml:"useTLS"`
-	TLSSkipVerify bool     `yaml:"tlsSkipVerify"`
-	SubjectPrefix string   `yaml:"subjectPrefix"`
-	Timeout       string   `yaml:"timeout"`
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	Cc       []string `yaml:"cc"`
+	Bcc      []string `yaml:"bcc"`
+	// Attachment 为 csv / ndjson 时将规则的样例文档作为附件，默认不附加
+	Attachment    string `yaml:"attachment"`
+	UseTLS        bool   `yaml:"useTLS"`
+	TLSSkipVerify bool   `yaml:"tlsSkipVerify"`
+	SubjectPrefix string `yaml:"subjectPrefix"`
+	Timeout       string `yaml:"timeout"`
+	// StartTLS 未使用 useTLS 时的 STARTTLS 策略：opportunistic（默认，服务器支持时启用）| required | none
+	StartTLS string `yaml:"startTLS"`
+	// AuthMechanism 认证方式：plain | login | cram-md5，默认按服务器声明的 AUTH 自动选择
+	AuthMechanism string `yaml:"authMechanism"`
+	// MaxIdleConns 连接池保留的空闲连接数，默认 2；IdleTimeout 空闲连接的最长保留时间，默认 1m
+	MaxIdleConns int    `yaml:"maxIdleConns"`
+	IdleTimeout  string `yaml:"idleTimeout"`
+	// RecipientGroups 收件人组，规则通过 alerts.email.groups 引用
+	RecipientGroups map[string]EmailRecipientGroup `yaml:"recipientGroups"`
+}
+
+// EmailRecipientGroup 一组邮件收件人
+type EmailRecipientGroup struct {
+	To  []string `yaml:"to"`
+	Cc  []string `yaml:"cc"`
+	Bcc []string `yaml:"bcc"`
 }
 
 func Load(path string) (*Config, error) {
@@ -0,0 +1,118 @@
+// Package discover 生成 Kibana / OpenSearch Dashboards Discover 的跳转链接，
+// 链接中预置规则的查询条件与告警的时间窗，便于在 Discover 中查看完整上下文。
+package discover
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Discover 链接的格式
+const (
+	// FlavorKibana Kibana 7.x / 8.x，以及 2.10 之前的 OpenSearch Dashboards（旧版 Discover）
+	FlavorKibana = "kibana"
+	// FlavorOpenSearch OpenSearch Dashboards 2.10 及以后的新版 Discover，查询条件放在 _q 中
+	FlavorOpenSearch = "opensearch"
+)
+
+// Query 描述一次 Discover 查询
+type Query struct {
+	// DataViewID Kibana 数据视图 / OpenSearch Dashboards 索引模式的 ID，为空时使用默认数据视图
+	DataViewID string
+	// QueryString 规则的 query_string（Lucene 语法），优先于 DSL
+	QueryString string
+	// DSL 规则的查询 DSL，作为自定义过滤条件放入链接
+	DSL any
+	// TimeField 排序使用的时间字段，默认 @timestamp
+	TimeField string
+	From, To  time.Time
+}
+
+// URL 返回 Discover 链接；baseURL 为 Kibana / OpenSearch Dashboards 的访问地址
+// （Kibana 使用非默认空间时带上 /s/<空间>，如 https://kibana.example.com/s/ops）
+func URL(baseURL, flavor string, q Query) string {
+	timeField := q.TimeField
+	if timeField == "" {
+		timeField = "@timestamp"
+	}
+	g := map[string]any{
+		"filters":         []any{},
+		"refreshInterval": map[string]any{"pause": true, "value": 0},
+		"time": map[string]any{
+			"from": q.From.UTC().Format("2006-01-02T15:04:05.000Z"),
+			"to":   q.To.UTC().Format("2006-01-02T15:04:05.000Z"),
+		},
+	}
+	query := map[string]any{"language": "kuery", "query": ""}
+	if q.QueryString != "" {
+		query = map[string]any{"language": "lucene", "query": q.QueryString}
+	}
+	filters := []any{}
+	if q.QueryString == "" && q.DSL != nil {
+		filters = append(filters, dslFilter(q.DataViewID, q.DSL))
+	}
+	sort := []any{[]any{timeField, "desc"}}
+
+	var state []string
+	switch flavor {
+	case FlavorOpenSearch:
+		a := map[string]any{
+			"discover": map[string]any{"columns": []any{"_source"}, "isDirty": false, "sort": sort},
+			"metadata": map[string]any{"indexPattern": q.DataViewID, "view": "discover"},
+		}
+		state = []string{
+			"_a=" + escape(Rison(a)),
+			"_g=" + escape(Rison(g)),
+			"_q=" + escape(Rison(map[string]any{"filters": filters, "query": query})),
+		}
+	default:
+		a := map[string]any{
+			"columns":  []any{},
+			"filters":  filters,
+			"interval": "auto",
+			"query":    query,
+			"sort":     sort,
+		}
+		if q.DataViewID != "" {
+			a["index"] = q.DataViewID
+		}
+		state = []string{
+			"_g=" + escape(Rison(g)),
+			"_a=" + escape(Rison(a)),
+		}
+	}
+	return strings.TrimRight(baseURL, "/") + "/app/discover#/?" + strings.Join(state, "&")
+}
+
+// dslFilter 将规则 DSL 包装为自定义过滤条件（与在 Discover 中“编辑为 Query DSL”得到的过滤条件一致）
+func dslFilter(dataViewID string, dsl any) map[string]any {
+	value, _ := json.Marshal(normalize(dsl))
+	meta := map[string]any{
+		"alias":    "规则查询 DSL",
+		"disabled": false,
+		"key":      "query",
+		"negate":   false,
+		"type":     "custom",
+		"value":    string(value),
+	}
+	if dataViewID != "" {
+		meta["index"] = dataViewID
+	}
+	return map[string]any{
+		"$state": map[string]any{"store": "appState"},
+		"meta":   meta,
+		"query":  dsl,
+	}
+}
+
+// escape 对 rison 做 URL 编码，保留 rison 的结构字符以便阅读（这些字符在 URL 片段中合法）
+func escape(s string) string {
+	e := strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+	return risonEscapes.Replace(e)
+}
+
+var risonEscapes = strings.NewReplacer(
+	"%21", "!", "%27", "'", "%28", "(", "%29", ")", "%2C", ",", "%3A", ":", "%2A", "*",
+)
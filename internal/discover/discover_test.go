@@ -0,0 +1,130 @@
+package discover
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// decodeState 拆分 Discover 链接，返回 # 之前的部分与解码后的 _g / _a / _q
+func decodeState(t *testing.T, link string) (string, map[string]string) {
+	t.Helper()
+	base, fragment, ok := strings.Cut(link, "#/?")
+	if !ok {
+		t.Fatalf("link %s has no discover fragment", link)
+	}
+	state := make(map[string]string)
+	for _, part := range strings.Split(fragment, "&") {
+		k, v, _ := strings.Cut(part, "=")
+		decoded, err := url.PathUnescape(v)
+		if err != nil {
+			t.Fatalf("unescape %s: %v", k, err)
+		}
+		state[k] = decoded
+	}
+	return base, state
+}
+
+func TestURL(t *testing.T) {
+	from := time.Date(2024, 5, 1, 9, 59, 0, 0, time.FixedZone("CST", 8*3600))
+	to := from.Add(5 * time.Minute)
+	g := "(filters:!(),refreshInterval:(pause:!t,value:0),time:(from:'2024-05-01T01:59:00.000Z',to:'2024-05-01T02:04:00.000Z'))"
+
+	tests := []struct {
+		name     string
+		baseURL  string
+		flavor   string
+		query    Query
+		wantBase string
+		want     map[string]string
+	}{
+		{
+			name:     "kibana default data view",
+			baseURL:  "https://kibana.example.com",
+			query:    Query{From: from, To: to},
+			wantBase: "https://kibana.example.com/app/discover",
+			want: map[string]string{
+				"_g": g,
+				"_a": "(columns:!(),filters:!(),interval:auto,query:(language:kuery,query:''),sort:!(!('@timestamp',desc)))",
+			},
+		},
+		{
+			name:     "kibana space with query string",
+			baseURL:  "https://kibana.example.com/s/ops/",
+			flavor:   FlavorKibana,
+			query:    Query{DataViewID: "logs-dv", QueryString: `level:ERROR AND message:"连接 超时!"`, From: from, To: to},
+			wantBase: "https://kibana.example.com/s/ops/app/discover",
+			want: map[string]string{
+				"_g": g,
+				"_a": `(columns:!(),filters:!(),index:logs-dv,interval:auto,query:(language:lucene,query:'level:ERROR AND message:"连接 超时!!"'),sort:!(!('@timestamp',desc)))`,
+			},
+		},
+		{
+			name:     "kibana dsl filter",
+			baseURL:  "https://kibana.example.com",
+			flavor:   FlavorKibana,
+			query:    Query{DataViewID: "logs-dv", DSL: map[any]any{"term": map[any]any{"level": "ERROR"}}, TimeField: "ts", From: from, To: to},
+			wantBase: "https://kibana.example.com/app/discover",
+			want: map[string]string{
+				"_g": g,
+				"_a": `(columns:!(),filters:!(('$state':(store:appState),meta:(alias:'规则查询 DSL',disabled:!f,index:logs-dv,key:query,negate:!f,type:custom,value:'{"term":{"level":"ERROR"}}'),query:(term:(level:ERROR)))),index:logs-dv,interval:auto,query:(language:kuery,query:''),sort:!(!(ts,desc)))`,
+			},
+		},
+		{
+			name:     "opensearch dashboards query string",
+			baseURL:  "https://osd.example.com/",
+			flavor:   FlavorOpenSearch,
+			query:    Query{DataViewID: "ip-1", QueryString: "it's broken", From: from, To: to},
+			wantBase: "https://osd.example.com/app/discover",
+			want: map[string]string{
+				"_a": "(discover:(columns:!(_source),isDirty:!f,sort:!(!('@timestamp',desc))),metadata:(indexPattern:ip-1,view:discover))",
+				"_g": g,
+				"_q": "(filters:!(),query:(language:lucene,query:'it!'s broken'))",
+			},
+		},
+		{
+			name:     "opensearch dashboards dsl filter",
+			baseURL:  "https://osd.example.com",
+			flavor:   FlavorOpenSearch,
+			query:    Query{DataViewID: "ip-1", DSL: map[string]any{"match_phrase": map[string]any{"message": "连接超时"}}, TimeField: "ts", From: from, To: to},
+			wantBase: "https://osd.example.com/app/discover",
+			want: map[string]string{
+				"_a": "(discover:(columns:!(_source),isDirty:!f,sort:!(!(ts,desc))),metadata:(indexPattern:ip-1,view:discover))",
+				"_g": g,
+				"_q": `(filters:!(('$state':(store:appState),meta:(alias:'规则查询 DSL',disabled:!f,index:ip-1,key:query,negate:!f,type:custom,value:'{"match_phrase":{"message":"连接超时"}}'),query:(match_phrase:(message:连接超时)))),query:(language:kuery,query:''))`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link := URL(tt.baseURL, tt.flavor, tt.query)
+			if strings.ContainsAny(link, " \"") {
+				t.Errorf("link contains unescaped characters: %s", link)
+			}
+			base, state := decodeState(t, link)
+			if base != tt.wantBase {
+				t.Errorf("base = %s, want %s", base, tt.wantBase)
+			}
+			if len(state) != len(tt.want) {
+				t.Errorf("state keys = %v, want %v", state, tt.want)
+			}
+			for k, want := range tt.want {
+				if state[k] != want {
+					t.Errorf("%s =\n  %s\nwant\n  %s", k, state[k], want)
+				}
+			}
+		})
+	}
+}
+
+func TestURLExact(t *testing.T) {
+	from := time.Date(2024, 5, 1, 1, 59, 0, 0, time.UTC)
+	got := URL("https://kibana.example.com", FlavorKibana, Query{DataViewID: "logs-dv", QueryString: "level:ERROR", From: from, To: from.Add(5 * time.Minute)})
+	want := "https://kibana.example.com/app/discover#/?" +
+		"_g=(filters:!(),refreshInterval:(pause:!t,value:0),time:(from:'2024-05-01T01:59:00.000Z',to:'2024-05-01T02:04:00.000Z'))" +
+		"&_a=(columns:!(),filters:!(),index:logs-dv,interval:auto,query:(language:lucene,query:'level:ERROR'),sort:!(!('%40timestamp',desc)))"
+	if got != want {
+		t.Errorf("URL =\n  %s\nwant\n  %s", got, want)
+	}
+}
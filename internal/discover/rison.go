@@ -0,0 +1,149 @@
+package discover
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// risonNotIDChars 不能出现在 rison 裸标识符中的字符，其余字符串需要用单引号包裹
+const risonNotIDChars = " '!:(),*@$"
+
+// Rison 将 JSON 兼容的值（map / slice / string / 数字 / bool / nil，或可以序列化为 JSON 的结构体）
+// 编码为 rison，Kibana 与 OpenSearch Dashboards 的 URL 状态（_g / _a / _q）使用该格式。对象的键按字典序输出。
+func Rison(v any) string {
+	var b strings.Builder
+	writeRison(&b, normalize(v))
+	return b.String()
+}
+
+// normalize 将 yaml 解析出的 map[any]any、结构体等统一为 map[string]any / []any
+func normalize(v any) any {
+	switch x := v.(type) {
+	case nil, bool, string, int, int64, float64, json.Number:
+		return x
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		for k, val := range x {
+			out[k] = normalize(val)
+		}
+		return out
+	case map[any]any:
+		out := make(map[string]any, len(x))
+		for k, val := range x {
+			out[fmt.Sprint(k)] = normalize(val)
+		}
+		return out
+	case []any:
+		out := make([]any, len(x))
+		for i, val := range x {
+			out[i] = normalize(val)
+		}
+		return out
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint())
+	case reflect.Float32:
+		return rv.Float()
+	}
+	// 其他类型（结构体、[]string 等）经由 JSON 转换
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	var out any
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+	if err := dec.Decode(&out); err != nil {
+		return string(data)
+	}
+	return normalize(out)
+}
+
+func writeRison(b *strings.Builder, v any) {
+	switch x := v.(type) {
+	case nil:
+		b.WriteString("!n")
+	case bool:
+		if x {
+			b.WriteString("!t")
+		} else {
+			b.WriteString("!f")
+		}
+	case int:
+		b.WriteString(strconv.Itoa(x))
+	case int64:
+		b.WriteString(strconv.FormatInt(x, 10))
+	case float64:
+		b.WriteString(strconv.FormatFloat(x, 'f', -1, 64))
+	case json.Number:
+		b.WriteString(strings.Replace(strings.ToLower(x.String()), "e+", "e", 1))
+	case string:
+		writeRisonString(b, x)
+	case []any:
+		b.WriteString("!(")
+		for i, item := range x {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeRison(b, item)
+		}
+		b.WriteByte(')')
+	case map[string]any:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.WriteByte('(')
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeRisonString(b, k)
+			b.WriteByte(':')
+			writeRison(b, x[k])
+		}
+		b.WriteByte(')')
+	default:
+		writeRisonString(b, fmt.Sprint(x))
+	}
+}
+
+func writeRisonString(b *strings.Builder, s string) {
+	if isRisonID(s) {
+		b.WriteString(s)
+		return
+	}
+	b.WriteByte('\'')
+	for _, r := range s {
+		if r == '\'' || r == '!' {
+			b.WriteByte('!')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('\'')
+}
+
+// isRisonID 判断字符串能否不加引号输出：非空、不以数字或 "-" 开头、不含保留字符
+func isRisonID(s string) bool {
+	if s == "" {
+		return false
+	}
+	if c := s[0]; c == '-' || (c >= '0' && c <= '9') {
+		return false
+	}
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(risonNotIDChars, r) {
+			return false
+		}
+	}
+	return true
+}
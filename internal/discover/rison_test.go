@@ -0,0 +1,76 @@
+package discover
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRison(t *testing.T) {
+	tests := []struct {
+		name string
+		in   any
+		want string
+	}{
+		{name: "nil", in: nil, want: "!n"},
+		{name: "true", in: true, want: "!t"},
+		{name: "false", in: false, want: "!f"},
+		{name: "int", in: 42, want: "42"},
+		{name: "negative", in: -7, want: "-7"},
+		{name: "float", in: 1.5, want: "1.5"},
+		{name: "json number exponent", in: json.Number("1E+21"), want: "1e21"},
+		{name: "empty string", in: "", want: "''"},
+		{name: "id", in: "discover", want: "discover"},
+		{name: "dotted id", in: "kubernetes.pod.name", want: "kubernetes.pod.name"},
+		{name: "space", in: "hello world", want: "'hello world'"},
+		{name: "single quote", in: "it's", want: "'it!'s'"},
+		{name: "bang", in: "wow!", want: "'wow!!'"},
+		{name: "quote and bang", in: "'!'", want: "'!'!!!''"},
+		{name: "double quote", in: `message:"timeout"`, want: `'message:"timeout"'`},
+		{name: "leading digit", in: "42abc", want: "'42abc'"},
+		{name: "leading dash", in: "-x", want: "'-x'"},
+		{name: "reserved chars", in: "a(b),c*d@e$f", want: "'a(b),c*d@e$f'"},
+		{name: "unicode", in: "连接超时", want: "连接超时"},
+		{name: "unicode with space", in: "连接 超时", want: "'连接 超时'"},
+		{name: "emoji", in: "🚨", want: "🚨"},
+		{name: "control char", in: "a\tb", want: "'a\tb'"},
+		{name: "empty array", in: []any{}, want: "!()"},
+		{name: "array", in: []any{"a", 1, true, nil}, want: "!(a,1,!t,!n)"},
+		{name: "string slice", in: []string{"a", "b c"}, want: "!(a,'b c')"},
+		{name: "empty object", in: map[string]any{}, want: "()"},
+		{name: "sorted keys", in: map[string]any{"b": 1, "a": "x y"}, want: "(a:'x y',b:1)"},
+		{name: "quoted key", in: map[string]any{"$state": map[string]any{"store": "appState"}}, want: "('$state':(store:appState))"},
+		{name: "yaml map", in: map[any]any{"term": map[any]any{"level": "ERROR"}}, want: "(term:(level:ERROR))"},
+		{name: "nested", in: map[string]any{"sort": []any{[]any{"@timestamp", "desc"}}}, want: "(sort:!(!('@timestamp',desc)))"},
+		{name: "struct", in: struct {
+			Field string `json:"field"`
+			Count int    `json:"count"`
+		}{Field: "level", Count: 3}, want: "(count:3,field:level)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Rison(tt.in); got != tt.want {
+				t.Errorf("Rison(%#v) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "(a:b,c:!(1,2))", want: "(a:b,c:!(1,2))"},
+		{in: "'hello world'", want: "'hello%20world'"},
+		{in: "'it!'s'", want: "'it!'s'"},
+		{in: "'@timestamp'", want: "'%40timestamp'"},
+		{in: `'message:"a&b#c"'`, want: "'message:%22a%26b%23c%22'"},
+		{in: "'a+b'", want: "'a%2Bb'"},
+		{in: "连接", want: "%E8%BF%9E%E6%8E%A5"},
+	}
+	for _, tt := range tests {
+		if got := escape(tt.in); got != tt.want {
+			t.Errorf("escape(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -3,10 +3,15 @@ package elasticsearch
 import (
 	"bytes"
 	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"sync/atomic"
 	"time"
 
 	"elasticsearch-alert/internal/config"
@@ -19,6 +24,9 @@ type Client struct {
 	provider string
 	es       *es.Client
 	os       *osv2.Client
+
+	// pitUnsupported 打开 PIT 失败（集群不支持）后不再尝试
+	pitUnsupported atomic.Bool
 }
 
 func NewClient(cfg config.ElasticsearchConfig) (*Client, error) {
@@ -153,3 +161,123 @@ func (c *Client) Get(index, id string) (*Response, error) {
 		}, nil
 	}
 }
+
+// ErrPITUnsupported 集群不支持 point in time（Elasticsearch 7.10 之前、OpenSearch 2.4 之前或未启用相应插件）
+var ErrPITUnsupported = errors.New("point in time is not supported by the cluster")
+
+// OpenPIT 为索引打开 point in time，返回 PIT ID。集群不支持时返回 ErrPITUnsupported，并在之后直接返回该错误
+func (c *Client) OpenPIT(index, keepAlive string) (string, error) {
+	if c.pitUnsupported.Load() {
+		return "", ErrPITUnsupported
+	}
+	var (
+		status int
+		body   io.ReadCloser
+	)
+	switch c.provider {
+	case "opensearch":
+		req, err := http.NewRequest(http.MethodPost, "/"+index+"/_search/point_in_time?keep_alive="+url.QueryEscape(keepAlive), nil)
+		if err != nil {
+			return "", err
+		}
+		res, err := c.os.Perform(req)
+		if err != nil {
+			return "", err
+		}
+		status, body = res.StatusCode, res.Body
+	default:
+		res, err := c.es.OpenPointInTime([]string{index}, keepAlive)
+		if err != nil {
+			return "", err
+		}
+		status, body = res.StatusCode, res.Body
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	if status >= 300 {
+		// 旧版本没有该接口时返回 400 / 404 / 405；索引不存在时同样是 404，需要区分
+		if (status == http.StatusBadRequest || status == http.StatusNotFound || status == http.StatusMethodNotAllowed) &&
+			!bytes.Contains(data, []byte("index_not_found_exception")) {
+			c.pitUnsupported.Store(true)
+			return "", fmt.Errorf("%w: [%d] %s", ErrPITUnsupported, status, data)
+		}
+		return "", fmt.Errorf("open point in time: [%d] %s", status, data)
+	}
+	var parsed struct {
+		ID    string `json:"id"`
+		PitID string `json:"pit_id"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return "", err
+	}
+	if parsed.PitID != "" {
+		return parsed.PitID, nil
+	}
+	return parsed.ID, nil
+}
+
+// ClosePIT 关闭 point in time；未关闭的 PIT 会在 keep_alive 到期后自动释放
+func (c *Client) ClosePIT(id string) error {
+	var (
+		status int
+		raw    string
+	)
+	switch c.provider {
+	case "opensearch":
+		data, _ := json.Marshal(map[string]any{"pit_id": []string{id}})
+		req, err := http.NewRequest(http.MethodDelete, "/_search/point_in_time", bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		res, err := c.os.Perform(req)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		status, raw = res.StatusCode, res.Status
+	default:
+		data, _ := json.Marshal(map[string]any{"id": id})
+		res, err := c.es.ClosePointInTime(c.es.ClosePointInTime.WithBody(bytes.NewReader(data)))
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		status, raw = res.StatusCode, res.String()
+	}
+	if status >= 300 {
+		return fmt.Errorf("close point in time: %s", raw)
+	}
+	return nil
+}
+
+// SearchPIT 在 point in time 上执行搜索，PIT ID 写在请求体的 pit 中，请求中不能再指定索引
+func (c *Client) SearchPIT(body *bytes.Buffer) (*Response, error) {
+	switch c.provider {
+	case "opensearch":
+		res, err := c.os.Search(c.os.Search.WithBody(body))
+		if err != nil {
+			return nil, err
+		}
+		return &Response{
+			Body:       res.Body,
+			statusCode: res.StatusCode,
+			raw:        res.String(),
+			isError:    res.IsError(),
+		}, nil
+	default:
+		res, err := c.es.Search(c.es.Search.WithBody(body))
+		if err != nil {
+			return nil, err
+		}
+		return &Response{
+			Body:       res.Body,
+			statusCode: res.StatusCode,
+			raw:        res.String(),
+			isError:    res.IsError(),
+		}, nil
+	}
+}
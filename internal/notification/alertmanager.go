@@ -0,0 +1,123 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AlertmanagerNotifier 将告警推送到 Prometheus Alertmanager 的 /api/v2/alerts，
+// 从而复用 Alertmanager 的静默、抑制与路由。
+// Alertmanager 要求告警持续期间不断重复推送，因此该渠道在每次规则评估时都会收到通知（不受静默期影响）。
+type AlertmanagerNotifier struct {
+	URLs           []string
+	Username       string
+	Password       string
+	BearerToken    string
+	ResolveTimeout time.Duration
+	Timeout        time.Duration
+}
+
+func (a *AlertmanagerNotifier) Name() string { return "alertmanager" }
+
+func (a *AlertmanagerNotifier) WantsResolved() bool { return true }
+
+func (a *AlertmanagerNotifier) WantsRepeat() bool { return true }
+
+func (a *AlertmanagerNotifier) Send(ctx context.Context, ev *AlertEvent) error {
+	b, _ := json.Marshal([]map[string]any{a.alert(ev, time.Now())})
+
+	// 高可用部署时需要推送到每个实例，只要有一个实例接收成功即可
+	var errs []error
+	for _, u := range a.URLs {
+		if err := a.post(ctx, u, b); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", u, err))
+		}
+	}
+	if len(errs) == len(a.URLs) {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// alert 构造 v2 API 的 postableAlert：规则名称、级别与规则 labels 作为标签，描述、样例日志与链接作为注解
+func (a *AlertmanagerNotifier) alert(d *AlertEvent, now time.Time) map[string]any {
+	labels := map[string]string{
+		"alertname": d.Rule,
+		"severity":  strings.ToLower(d.Severity),
+		"source":    "elasticsearch-alert",
+	}
+	for k, v := range d.Labels {
+		labels[k] = v
+	}
+
+	annotations := map[string]string{"summary": d.Title}
+	if d.Summary.Description != "" {
+		annotations["description"] = d.Summary.Description
+	}
+	if d.Summary.Log != "" {
+		annotations["sample_message"] = d.Summary.Log
+	}
+	if d.Summary.DetailURL != "" {
+		annotations["detail_url"] = d.Summary.DetailURL
+	}
+	if d.Summary.DiscoverURL != "" {
+		annotations["discover_url"] = d.Summary.DiscoverURL
+	}
+	if d.Summary.RunbookURL != "" {
+		annotations["runbook_url"] = d.Summary.RunbookURL
+	}
+
+	startsAt := d.StartsAt
+	if startsAt.IsZero() {
+		startsAt = now
+	}
+	// 告警中时 endsAt 设为未来时间，持续推送会不断刷新；恢复时设为恢复时间，Alertmanager 随即发送恢复通知。
+	// 未来时间取规则评估间隔的 3 倍（容忍偶尔一两次评估失败），且不短于 ResolveTimeout，
+	// 否则评估间隔较长的规则会在两次评估之间被 Alertmanager 自动恢复
+	endsAt := now.Add(max(a.ResolveTimeout, 3*d.EvalInterval))
+	if d.Status == StatusResolved && !d.EndsAt.IsZero() {
+		endsAt = d.EndsAt
+	}
+	alert := map[string]any{
+		"labels":      labels,
+		"annotations": annotations,
+		"startsAt":    startsAt.UTC().Format(time.RFC3339),
+		"endsAt":      endsAt.UTC().Format(time.RFC3339),
+	}
+	if d.Summary.DetailURL != "" {
+		alert["generatorURL"] = d.Summary.DetailURL
+	}
+	return alert
+}
+
+func (a *AlertmanagerNotifier) post(ctx context.Context, baseURL string, body []byte) error {
+	endpoint := strings.TrimRight(baseURL, "/") + "/api/v2/alerts"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if a.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+a.BearerToken)
+	} else if a.Username != "" {
+		req.SetBasicAuth(a.Username, a.Password)
+	}
+	client := &http.Client{Timeout: a.Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return statusError("alertmanager", resp, respBody)
+	}
+	return nil
+}
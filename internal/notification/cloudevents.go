@@ -0,0 +1,182 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	cloudEventsSpecVersion = "1.0"
+	cloudEventsSource      = "/elasticsearch-alert"
+	cloudEventsTypePrefix  = "com.elasticsearch-alert.alert"
+)
+
+// CloudEvent 是 CloudEvents 1.0 结构化模式（JSON）的告警事件。
+// partitionkey 为规则名称（Partitioning 扩展），同一规则的事件在 Kafka 中保持顺序。
+type CloudEvent struct {
+	SpecVersion     string      `json:"specversion"`
+	ID              string      `json:"id"`
+	Source          string      `json:"source"`
+	Type            string      `json:"type"`
+	Subject         string      `json:"subject,omitempty"`
+	Time            time.Time   `json:"time"`
+	DataContentType string      `json:"datacontenttype"`
+	PartitionKey    string      `json:"partitionkey,omitempty"`
+	Data            AlertRecord `json:"data"`
+}
+
+// cloudEventFormat 保存事件的 source 与 type 前缀
+type cloudEventFormat struct {
+	Source     string
+	TypePrefix string
+}
+
+func newCloudEventFormat(source, typePrefix string) cloudEventFormat {
+	if source == "" {
+		source = cloudEventsSource
+	}
+	if typePrefix == "" {
+		typePrefix = cloudEventsTypePrefix
+	}
+	return cloudEventFormat{Source: source, TypePrefix: strings.TrimRight(typePrefix, ".")}
+}
+
+// build 将告警事件转换为 CloudEvent。id 由指纹、状态与评估时间计算，
+// 同一事件重试发送时 id 不变，消费方可据此去重。
+func (f cloudEventFormat) build(ev *AlertEvent) CloudEvent {
+	status := ev.Status
+	if status == "" {
+		status = StatusFiring
+	}
+	ts := ev.EvaluatedAt
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s|%d", ev.Fingerprint, ev.Rule, status, ts.UnixNano())))
+
+	return CloudEvent{
+		SpecVersion:     cloudEventsSpecVersion,
+		ID:              hex.EncodeToString(sum[:16]),
+		Source:          f.Source,
+		Type:            f.TypePrefix + "." + status,
+		Subject:         ev.Rule,
+		Time:            ts,
+		DataContentType: "application/json",
+		PartitionKey:    ev.Rule,
+		Data:            newAlertRecord(ev),
+	}
+}
+
+// KafkaNotifier 通过 Kafka REST Proxy（v2 API）将 CloudEvent 写入 Kafka 主题，
+// 消息 key 为规则名称，因此同一规则的事件落在同一分区。发送失败时由分发器负责重试。
+type KafkaNotifier struct {
+	Format       cloudEventFormat
+	RestProxyURL string
+	Topic        string
+	Username     string
+	Password     string
+	Timeout      time.Duration
+}
+
+func (k *KafkaNotifier) Name() string { return "kafka" }
+
+func (k *KafkaNotifier) WantsResolved() bool { return true }
+
+func (k *KafkaNotifier) WantsAcked() bool { return true }
+
+func (k *KafkaNotifier) Send(ctx context.Context, ev *AlertEvent) error {
+	ce := k.Format.build(ev)
+	payload := map[string]any{
+		"records": []map[string]any{{"key": ce.PartitionKey, "value": ce}},
+	}
+	b, _ := json.Marshal(payload)
+	endpoint := strings.TrimRight(k.RestProxyURL, "/") + "/topics/" + url.PathEscape(k.Topic)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	if k.Username != "" {
+		req.SetBasicAuth(k.Username, k.Password)
+	}
+	client := &http.Client{Timeout: k.Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return statusError("kafka rest proxy", resp, body)
+	}
+
+	// REST Proxy 对每条记录单独返回结果：error_code 1 为不可重试，2 为可重试
+	var res struct {
+		Offsets []struct {
+			Partition *int   `json:"partition"`
+			Offset    *int64 `json:"offset"`
+			ErrorCode *int   `json:"error_code"`
+			Error     string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return fmt.Errorf("kafka rest proxy: decode response: %w", err)
+	}
+	for _, o := range res.Offsets {
+		if o.ErrorCode == nil {
+			continue
+		}
+		err := fmt.Errorf("kafka rest proxy error_code=%d error=%s", *o.ErrorCode, o.Error)
+		if *o.ErrorCode == 2 {
+			return Temporary(err, 0)
+		}
+		return Permanent(err)
+	}
+	return nil
+}
+
+// EventFileNotifier 将 CloudEvent 按行追加写入本地文件（NDJSON），便于测试与离线分析
+type EventFileNotifier struct {
+	Format cloudEventFormat
+	Path   string
+
+	mu sync.Mutex
+}
+
+func (f *EventFileNotifier) Name() string { return "eventfile" }
+
+func (f *EventFileNotifier) WantsResolved() bool { return true }
+
+func (f *EventFileNotifier) WantsAcked() bool { return true }
+
+func (f *EventFileNotifier) Send(ctx context.Context, ev *AlertEvent) error {
+	line, err := json.Marshal(f.Format.build(ev))
+	if err != nil {
+		return Permanent(err)
+	}
+	line = append(line, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(line); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
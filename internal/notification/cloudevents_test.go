@@ -0,0 +1,209 @@
+package notification
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// restProxyRecord REST Proxy 写入请求中的一条记录
+type restProxyRecord struct {
+	Key   string     `json:"key"`
+	Value CloudEvent `json:"value"`
+}
+
+// restProxyRequest REST Proxy 收到的一次写入
+type restProxyRequest struct {
+	path        string
+	contentType string
+	user        string
+	records     []restProxyRecord
+}
+
+// newRestProxy 模拟 Kafka REST Proxy（v2 API），按 respond 返回的状态码与响应体应答
+func newRestProxy(t *testing.T, respond func() (int, string)) (*httptest.Server, *[]restProxyRequest) {
+	t.Helper()
+	var reqs []restProxyRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := restProxyRequest{path: r.URL.Path, contentType: r.Header.Get("Content-Type")}
+		req.user, _, _ = r.BasicAuth()
+		var body struct {
+			Records []restProxyRecord `json:"records"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request body: %v", err)
+		}
+		req.records = body.Records
+		reqs = append(reqs, req)
+		status, resp := http.StatusOK, `{"offsets":[{"partition":0,"offset":1}]}`
+		if respond != nil {
+			status, resp = respond()
+		}
+		w.WriteHeader(status)
+		fmt.Fprint(w, resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &reqs
+}
+
+func testCloudEvent(status string) *AlertEvent {
+	ev := testIncidentEvent(status)
+	ev.EvaluatedAt = time.Date(2024, 5, 1, 10, 5, 0, 0, time.UTC)
+	ev.Count = 42
+	return ev
+}
+
+func TestKafkaNotifier(t *testing.T) {
+	srv, reqs := newRestProxy(t, nil)
+	k := &KafkaNotifier{
+		Format:       newCloudEventFormat("", "com.example.alert."),
+		RestProxyURL: srv.URL + "/",
+		Topic:        "log-alerts",
+		Username:     "user",
+		Password:     "pass",
+		Timeout:      time.Second,
+	}
+	statuses := []string{StatusFiring, StatusAcked, StatusResolved}
+	for _, status := range statuses {
+		if err := k.Send(context.Background(), testCloudEvent(status)); err != nil {
+			t.Fatalf("send %s: %v", status, err)
+		}
+	}
+	if len(*reqs) != len(statuses) {
+		t.Fatalf("got %d requests, want %d", len(*reqs), len(statuses))
+	}
+	ids := make(map[string]bool)
+	for i, req := range *reqs {
+		if req.path != "/topics/log-alerts" {
+			t.Errorf("request %d: path = %s", i, req.path)
+		}
+		if req.contentType != "application/vnd.kafka.json.v2+json" {
+			t.Errorf("request %d: Content-Type = %s", i, req.contentType)
+		}
+		if req.user != "user" {
+			t.Errorf("request %d: basic auth user = %q", i, req.user)
+		}
+		if len(req.records) != 1 {
+			t.Fatalf("request %d: got %d records, want 1", i, len(req.records))
+		}
+		rec := req.records[0]
+		// 消息 key 与 partitionkey 均为规则名称，同一规则的事件落在同一分区
+		if rec.Key != "k8s-error" || rec.Value.PartitionKey != "k8s-error" {
+			t.Errorf("request %d: key = %q, partitionkey = %q, want the rule name", i, rec.Key, rec.Value.PartitionKey)
+		}
+		ce := rec.Value
+		if want := "com.example.alert." + statuses[i]; ce.Type != want {
+			t.Errorf("request %d: type = %s, want %s", i, ce.Type, want)
+		}
+		if ce.SpecVersion != "1.0" || ce.Source != "/elasticsearch-alert" || ce.Subject != "k8s-error" {
+			t.Errorf("request %d: specversion/source/subject = %s/%s/%s", i, ce.SpecVersion, ce.Source, ce.Subject)
+		}
+		if ce.Data.Status != statuses[i] || ce.Data.Count != 42 || ce.Data.Fingerprint != "esalert-0123456789abcdef" {
+			t.Errorf("request %d: data = %+v", i, ce.Data)
+		}
+		ids[ce.ID] = true
+	}
+	if len(ids) != len(statuses) {
+		t.Errorf("event ids should differ per status, got %v", ids)
+	}
+}
+
+func TestKafkaNotifierRetryKeepsID(t *testing.T) {
+	calls := 0
+	srv, reqs := newRestProxy(t, func() (int, string) {
+		calls++
+		if calls == 1 {
+			return http.StatusServiceUnavailable, `{"error_code":50301,"message":"unavailable"}`
+		}
+		return http.StatusOK, `{"offsets":[{"partition":0,"offset":1}]}`
+	})
+	k := &KafkaNotifier{Format: newCloudEventFormat("", ""), RestProxyURL: srv.URL, Topic: "t", Timeout: time.Second}
+	ev := testCloudEvent(StatusFiring)
+	err := k.Send(context.Background(), ev)
+	if retryable, _ := classify(err); err == nil || !retryable {
+		t.Fatalf("err = %v, want a retryable error", err)
+	}
+	if err := k.Send(context.Background(), ev); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	// 重试发送的事件 id 不变，消费方可据此去重
+	if a, b := (*reqs)[0].records[0].Value.ID, (*reqs)[1].records[0].Value.ID; a != b {
+		t.Errorf("retry changed event id: %s != %s", a, b)
+	}
+}
+
+func TestKafkaNotifierErrorClassification(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		retryable bool
+	}{
+		{name: "server error", status: http.StatusInternalServerError, body: `{}`, retryable: true},
+		{name: "rate limited", status: http.StatusTooManyRequests, body: `{}`, retryable: true},
+		{name: "unknown topic", status: http.StatusNotFound, body: `{"error_code":40401}`},
+		{name: "unauthorized", status: http.StatusUnauthorized, body: `{}`},
+		{name: "retriable record error", status: http.StatusOK, body: `{"offsets":[{"error_code":2,"error":"leader not available"}]}`, retryable: true},
+		{name: "record error", status: http.StatusOK, body: `{"offsets":[{"error_code":1,"error":"record too large"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newRestProxy(t, func() (int, string) { return tt.status, tt.body })
+			k := &KafkaNotifier{Format: newCloudEventFormat("", ""), RestProxyURL: srv.URL, Topic: "t", Timeout: time.Second}
+			err := k.Send(context.Background(), testCloudEvent(StatusFiring))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if retryable, _ := classify(err); retryable != tt.retryable {
+				t.Errorf("retryable = %v, want %v (err=%v)", retryable, tt.retryable, err)
+			}
+		})
+	}
+}
+
+func TestEventFileNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	f := &EventFileNotifier{Format: newCloudEventFormat("/test", ""), Path: path}
+	statuses := []string{StatusFiring, StatusAcked, StatusResolved}
+	for _, status := range statuses {
+		if err := f.Send(context.Background(), testCloudEvent(status)); err != nil {
+			t.Fatalf("send %s: %v", status, err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var events []CloudEvent
+	sc := bufio.NewScanner(file)
+	for sc.Scan() {
+		var ce CloudEvent
+		if err := json.Unmarshal(sc.Bytes(), &ce); err != nil {
+			t.Fatalf("line %d: %v", len(events)+1, err)
+		}
+		events = append(events, ce)
+	}
+	if len(events) != len(statuses) {
+		t.Fatalf("got %d lines, want %d", len(events), len(statuses))
+	}
+	for i, ce := range events {
+		if want := cloudEventsTypePrefix + "." + statuses[i]; ce.Type != want {
+			t.Errorf("line %d: type = %s, want %s", i+1, ce.Type, want)
+		}
+		if ce.Source != "/test" || ce.PartitionKey != "k8s-error" {
+			t.Errorf("line %d: source = %s, partitionkey = %s", i+1, ce.Source, ce.PartitionKey)
+		}
+		if !ce.Time.Equal(time.Date(2024, 5, 1, 10, 5, 0, 0, time.UTC)) {
+			t.Errorf("line %d: time = %s, want the evaluation time", i+1, ce.Time)
+		}
+	}
+}
@@ -7,78 +7,221 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
+
+	"elasticsearch-alert/internal/config"
+	"elasticsearch-alert/internal/logging"
+)
+
+const (
+	dingTalkErrSystemBusy   = -1
+	dingTalkErrInvalidToken = 40014
+	dingTalkErrTokenExpired = 42001
+	dingTalkErrRateLimited  = 130101
+	// dingTalkMaxBytes markdown 消息正文的上限约 20000 字节
+	dingTalkMaxBytes = 20000
+	dingTalkAPIURL   = "https://oapi.dingtalk.com"
+)
+
+// 钉钉消息类型
+const (
+	DingTalkMarkdown   = "markdown"
+	DingTalkActionCard = "actionCard"
 )
 
 type DingTalkNotifier struct {
-	Webhook     string
-	Secret      string
-	EnableAtAll bool
-	Timeout     time.Duration
+	Webhook         string
+	Secret          string
+	EnableAtAll     bool
+	AtAllSeverities []string
+	AtMobiles       []string
+	AtUserIDs       []string
+	Timeout         time.Duration
+	// MaxBytes 消息正文的大小预算，超出时按优先级收缩内容
+	MaxBytes    int
+	MessageType string
 }
 
-func (d *DingTalkNotifier) Name() string { return "dingtalk" }
+// NewDingTalkNotifier 根据配置创建钉钉群机器人渠道
+func NewDingTalkNotifier(cfg config.DingTalkConfig) (*DingTalkNotifier, error) {
+	messageType, err := dingTalkMessageType(cfg.MessageType)
+	if err != nil {
+		return nil, err
+	}
+	return &DingTalkNotifier{
+		Webhook:         cfg.Webhook,
+		Secret:          cfg.Secret,
+		EnableAtAll:     cfg.EnableAtAll,
+		AtAllSeverities: cfg.AtAllSeverities,
+		AtMobiles:       cfg.AtMobiles,
+		AtUserIDs:       cfg.AtUserIDs,
+		Timeout:         parseDurationDefault(cfg.Timeout, 5*time.Second),
+		MaxBytes:        cfg.MaxBytes,
+		MessageType:     messageType,
+	}, nil
+}
 
-func (d *DingTalkNotifier) Send(ctx context.Context, title, text string) error {
-	// 参考 opensearch-alert-main 的 Markdown 模板，增加 Emoji 与标签
-	content := fmt.Sprintf("**🚨 Elasticsearch 日志告警**\n\n"+
-		"🏷️ **规则/标题：** %s\n\n"+
-		"📝 **详情：**\n%s",
-		title, text)
+func dingTalkMessageType(t string) (string, error) {
+	switch t {
+	case "":
+		return DingTalkMarkdown, nil
+	case DingTalkMarkdown, DingTalkActionCard:
+		return t, nil
+	default:
+		return "", fmt.Errorf("dingtalk: unknown messageType %q", t)
+	}
+}
+
+func (d *DingTalkNotifier) Name() string { return "dingtalk" }
 
-	// 钉钉 Markdown 中手动追加 @所有人 提示
-	if d.EnableAtAll {
-		content += "\n\n@所有人"
+func (d *DingTalkNotifier) Send(ctx context.Context, ev *AlertEvent) error {
+	// 被 @ 的手机号 / userid 需要同时出现在正文中，钉钉才会高亮提醒
+	isAtAll, atMobiles, atUserIDs := d.mentions(ev)
+	var mentions []string
+	if isAtAll {
+		mentions = append(mentions, "@所有人")
+	}
+	for _, m := range atMobiles {
+		mentions = append(mentions, "@"+m)
+	}
+	for _, u := range atUserIDs {
+		mentions = append(mentions, "@"+u)
+	}
+	at := map[string]any{
+		"isAtAll":   isAtAll,
+		"atMobiles": atMobiles,
+		"atUserIds": atUserIDs,
 	}
 
-	webhookURL := d.Webhook
-	if d.Secret != "" {
-		webhookURL = d.addSign(webhookURL, d.Secret)
+	// actionCard 不支持 @，需要提醒时额外发送一条 text 消息
+	if d.MessageType == DingTalkActionCard {
+		if btns := dingTalkButtons(ev.Summary); len(btns) > 0 {
+			title, text := dingTalkContent(ev, d.MaxBytes, "")
+			var list []map[string]string
+			for _, b := range btns {
+				list = append(list, map[string]string{"title": b.title, "actionURL": b.url})
+			}
+			err := d.post(ctx, map[string]any{
+				"msgtype": "actionCard",
+				"actionCard": map[string]any{
+					"title":          title,
+					"text":           text,
+					"btnOrientation": dingTalkBtnOrientation(btns),
+					"btns":           list,
+				},
+			})
+			if err != nil || len(mentions) == 0 {
+				return err
+			}
+			// 告警卡片已经送达，提醒消息失败只记录日志，不返回错误，避免重试时卡片重复发送
+			err = d.post(ctx, map[string]any{
+				"msgtype": "text",
+				"text":    map[string]string{"content": mentionText(ev) + " " + strings.Join(mentions, " ")},
+				"at":      at,
+			})
+			if err != nil {
+				logging.Errorf("钉钉 @ 提醒消息发送失败: %v", err)
+			}
+			return nil
+		}
 	}
 
-	payload := map[string]any{
+	footer := ""
+	if len(mentions) > 0 {
+		footer = "\n\n" + strings.Join(mentions, " ")
+	}
+	title, text := dingTalkContent(ev, d.MaxBytes, footer)
+	return d.post(ctx, map[string]any{
 		"msgtype": "markdown",
 		"markdown": map[string]string{
-			"title": "Elasticsearch 日志告警",
-			"text":  content,
-		},
-		"at": map[string]any{
-			"isAtAll": d.EnableAtAll,
+			"title": title,
+			"text":  text,
 		},
+		"at": at,
+	})
+}
+
+// Cost actionCard 需要 @ 提醒时一次发送调用两次 webhook，消耗两个限流令牌
+func (d *DingTalkNotifier) Cost(ev *AlertEvent) int {
+	if d.MessageType != DingTalkActionCard || len(dingTalkButtons(ev.Summary)) == 0 {
+		return 1
 	}
-	b, _ := json.Marshal(payload)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(b))
-	if err != nil {
-		return err
+	if isAtAll, atMobiles, atUserIDs := d.mentions(ev); isAtAll || len(atMobiles) > 0 || len(atUserIDs) > 0 {
+		return 2
 	}
-	req.Header.Set("Content-Type", "application/json")
-	client := &http.Client{Timeout: d.Timeout}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
+	return 1
+}
+
+// mentions 返回是否 @所有人，以及需要 @ 的手机号与 userid（含值班人员），恢复 / 确认时不再 @
+func (d *DingTalkNotifier) mentions(ev *AlertEvent) (bool, []string, []string) {
+	if !mentionable(ev) {
+		return false, nil, nil
 	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode >= 300 {
-		return fmt.Errorf("dingtalk webhook status=%d body=%s", resp.StatusCode, string(body))
+	var recipientMobiles, recipientIDs []string
+	for _, r := range ev.Recipients {
+		recipientMobiles = append(recipientMobiles, r.DingTalkMobile)
+		recipientIDs = append(recipientIDs, r.DingTalkUserID)
 	}
+	return atAll(d.EnableAtAll, d.AtAllSeverities, ev.Severity),
+		mergeUnique(d.AtMobiles, ev.Mentions.DingTalkMobiles, recipientMobiles),
+		mergeUnique(d.AtUserIDs, ev.Mentions.DingTalkUserIDs, recipientIDs)
+}
 
-	// 钉钉即使失败也会返回 200，通过 errcode 判断是否成功
-	var res struct {
-		ErrCode int    `json:"errcode"`
-		ErrMsg  string `json:"errmsg"`
+// dingTalkContent 渲染钉钉 markdown 正文，footer 为追加在末尾的 @ 文本
+func dingTalkContent(ev *AlertEvent, maxBytes int, footer string) (title, text string) {
+	// 参考 opensearch-alert-main 的 Markdown 模板，增加 Emoji 与标签
+	header := fmt.Sprintf("**%s Elasticsearch 日志告警**\n\n"+
+		"🏷️ **规则/标题：** %s\n\n"+
+		"📝 **详情：**\n",
+		statusIcon(ev.Status), ev.Title)
+	ev = fitEvent(ev, maxBytesDefault(maxBytes, dingTalkMaxBytes)-len(header)-len(footer))
+	return "Elasticsearch 日志告警", header + ev.Text + footer
+}
+
+type dingTalkButton struct {
+	title string
+	url   string
+}
+
+// dingTalkButtons 返回 actionCard 的按钮：查看日志详情、Discover、Ack（确认页面）与 Runbook
+func dingTalkButtons(sum Summary) []dingTalkButton {
+	var btns []dingTalkButton
+	if sum.DetailURL != "" {
+		btns = append(btns, dingTalkButton{"查看日志详情", sum.DetailURL})
 	}
-	if err := json.Unmarshal(body, &res); err == nil {
-		if res.ErrCode != 0 {
-			return fmt.Errorf("dingtalk webhook errcode=%d errmsg=%s body=%s", res.ErrCode, res.ErrMsg, string(body))
-		}
+	if sum.DiscoverURL != "" {
+		btns = append(btns, dingTalkButton{"Discover", sum.DiscoverURL})
 	}
-	return nil
+	if sum.AckURL != "" {
+		btns = append(btns, dingTalkButton{"Ack", sum.AckURL})
+	}
+	if sum.RunbookURL != "" {
+		btns = append(btns, dingTalkButton{"Runbook", sum.RunbookURL})
+	}
+	return btns
+}
+
+// dingTalkBtnOrientation 按钮不超过 3 个时横向排列，否则竖向排列
+func dingTalkBtnOrientation(btns []dingTalkButton) string {
+	if len(btns) <= 3 {
+		return "1"
+	}
+	return "0"
+}
+
+func (d *DingTalkNotifier) post(ctx context.Context, payload map[string]any) error {
+	webhookURL := d.Webhook
+	if d.Secret != "" {
+		webhookURL = d.addSign(webhookURL, d.Secret)
+	}
+	return dingTalkPost(ctx, d.Timeout, webhookURL, payload, nil)
 }
 
 // addSign 按钉钉官方文档对 webhook 进行加签
@@ -100,3 +243,198 @@ func (d *DingTalkNotifier) addSign(webhookURL, secret string) string {
 	u.RawQuery = q.Encode()
 	return u.String()
 }
+
+// DingTalkWorkNotifier 以企业内部应用的工作通知发送给指定用户 / 部门。工作通知直接出现在个人的
+// “工作通知”会话中，不受群机器人免打扰影响；通过 Severities 可以只对高级别告警使用。
+type DingTalkWorkNotifier struct {
+	AppKey      string
+	AppSecret   string
+	AgentID     int64
+	UserIDs     []string
+	DeptIDs     []string
+	Severities  []string
+	APIURL      string
+	Timeout     time.Duration
+	MaxBytes    int
+	MessageType string
+
+	token *accessToken
+}
+
+// NewDingTalkWorkNotifier 根据钉钉渠道的 app 配置创建工作通知渠道
+func NewDingTalkWorkNotifier(cfg config.DingTalkConfig) (*DingTalkWorkNotifier, error) {
+	messageType, err := dingTalkMessageType(cfg.MessageType)
+	if err != nil {
+		return nil, err
+	}
+	d := &DingTalkWorkNotifier{
+		AppKey:      cfg.App.AppKey,
+		AppSecret:   cfg.App.AppSecret,
+		AgentID:     cfg.App.AgentID,
+		UserIDs:     cfg.App.UserIDs,
+		DeptIDs:     cfg.App.DeptIDs,
+		Severities:  cfg.App.Severities,
+		APIURL:      cfg.App.APIURL,
+		Timeout:     parseDurationDefault(cfg.Timeout, 5*time.Second),
+		MaxBytes:    cfg.MaxBytes,
+		MessageType: messageType,
+	}
+	d.token = &accessToken{fetch: d.fetchToken}
+	return d, nil
+}
+
+func (d *DingTalkWorkNotifier) Name() string { return "dingtalk_work" }
+
+// Accepts 只接收 Severities 中的级别，与 atAllSeverities 的匹配规则相同：未配置 severities 时所有级别都发送
+func (d *DingTalkWorkNotifier) Accepts(ev *AlertEvent) bool {
+	return atAll(true, d.Severities, ev.Severity)
+}
+
+func (d *DingTalkWorkNotifier) Send(ctx context.Context, ev *AlertEvent) error {
+	var recipientIDs []string
+	for _, r := range ev.Recipients {
+		recipientIDs = append(recipientIDs, r.DingTalkUserID)
+	}
+	users := mergeUnique(d.UserIDs, ev.Mentions.DingTalkUserIDs, recipientIDs)
+	if len(users) == 0 && len(d.DeptIDs) == 0 {
+		return Permanent(fmt.Errorf("dingtalk work: no receivers"))
+	}
+
+	title, text := dingTalkContent(ev, d.MaxBytes, "")
+	msg := map[string]any{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"title": title, "text": text},
+	}
+	if btns := dingTalkButtons(ev.Summary); d.MessageType == DingTalkActionCard && len(btns) > 0 {
+		var list []map[string]string
+		for _, b := range btns {
+			list = append(list, map[string]string{"title": b.title, "action_url": b.url})
+		}
+		msg = map[string]any{
+			"msgtype": "action_card",
+			"action_card": map[string]any{
+				"title":           title,
+				"markdown":        text,
+				"btn_orientation": dingTalkBtnOrientation(btns),
+				"btn_json_list":   list,
+			},
+		}
+	}
+	payload := map[string]any{
+		"agent_id": d.AgentID,
+		"msg":      msg,
+	}
+	if len(users) > 0 {
+		payload["userid_list"] = strings.Join(users, ",")
+	}
+	if len(d.DeptIDs) > 0 {
+		payload["dept_id_list"] = strings.Join(d.DeptIDs, ",")
+	}
+
+	var res struct {
+		TaskID int64 `json:"task_id"`
+	}
+	for attempt := 0; ; attempt++ {
+		token, err := d.token.Get(ctx)
+		if err != nil {
+			return err
+		}
+		endpoint := d.apiURL() + "/topapi/message/corpconversation/asyncsend_v2?access_token=" + url.QueryEscape(token)
+		err = dingTalkPost(ctx, d.Timeout, endpoint, payload, &res)
+		var de *dingTalkError
+		if attempt == 0 && errors.As(err, &de) && de.tokenInvalid() {
+			d.token.Invalidate()
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		break
+	}
+	logging.Debugf("钉钉工作通知已提交: rule=%s task_id=%d", ev.Rule, res.TaskID)
+	return nil
+}
+
+func (d *DingTalkWorkNotifier) apiURL() string {
+	if d.APIURL != "" {
+		return strings.TrimRight(d.APIURL, "/")
+	}
+	return dingTalkAPIURL
+}
+
+// fetchToken 获取企业内部应用的 access_token
+func (d *DingTalkWorkNotifier) fetchToken(ctx context.Context) (string, time.Duration, error) {
+	endpoint := fmt.Sprintf("%s/gettoken?appkey=%s&appsecret=%s",
+		d.apiURL(), url.QueryEscape(d.AppKey), url.QueryEscape(d.AppSecret))
+	var res struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := dingTalkPost(ctx, d.Timeout, endpoint, nil, &res); err != nil {
+		return "", 0, fmt.Errorf("dingtalk gettoken: %w", err)
+	}
+	return res.AccessToken, time.Duration(res.ExpiresIn) * time.Second, nil
+}
+
+// dingTalkError 钉钉在响应体中通过 errcode 返回的错误
+type dingTalkError struct {
+	Code int
+	Msg  string
+	Body string
+}
+
+func (e *dingTalkError) Error() string {
+	return fmt.Sprintf("dingtalk errcode=%d errmsg=%s body=%s", e.Code, e.Msg, e.Body)
+}
+
+func (e *dingTalkError) tokenInvalid() bool {
+	return e.Code == dingTalkErrInvalidToken || e.Code == dingTalkErrTokenExpired
+}
+
+// dingTalkPost 发送请求并检查响应中的 errcode，payload 为 nil 时发送 GET 请求
+func dingTalkPost(ctx context.Context, timeout time.Duration, endpoint string, payload map[string]any, out any) error {
+	method, body := http.MethodGet, io.Reader(nil)
+	if payload != nil {
+		b, _ := json.Marshal(payload)
+		method, body = http.MethodPost, bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return statusError("dingtalk", resp, data)
+	}
+
+	// 钉钉即使失败也会返回 200，通过 errcode 判断是否成功
+	var res struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(data, &res); err == nil && res.ErrCode != 0 {
+		de := &dingTalkError{Code: res.ErrCode, Msg: res.ErrMsg, Body: string(data)}
+		switch {
+		case res.ErrCode == dingTalkErrRateLimited:
+			// 机器人每分钟最多 20 条，超限后需要等待一段时间再发
+			return Temporary(de, time.Minute)
+		case res.ErrCode == dingTalkErrSystemBusy || de.tokenInvalid():
+			return Temporary(de, 0)
+		default:
+			return Permanent(de)
+		}
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("dingtalk: decode response: %w", err)
+		}
+	}
+	return nil
+}
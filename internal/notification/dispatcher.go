@@ -0,0 +1,546 @@
+package notification
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	mrand "math/rand"
+	"sync"
+	"time"
+
+	"elasticsearch-alert/internal/config"
+	"elasticsearch-alert/internal/logging"
+)
+
+// Message 是排队等待发送的一条通知
+type Message struct {
+	ID        string      `json:"id"`
+	Channel   string      `json:"channel"`
+	Rule      string      `json:"rule"`
+	Event     *AlertEvent `json:"event"`
+	Attempts  int         `json:"attempts"`
+	CreatedAt time.Time   `json:"createdAt"`
+	NextAt    time.Time   `json:"nextAt"`
+	LastError string      `json:"lastError,omitempty"`
+	// Merged 仅用于限流汇总消息：被合并的告警按规则统计的条数
+	Merged map[string]int `json:"merged,omitempty"`
+}
+
+// ChannelStats 渠道发送统计
+type ChannelStats struct {
+	Channel      string `json:"channel"`
+	Queued       int    `json:"queued"` // 当前排队中的条数
+	Enqueued     int64  `json:"enqueued"`
+	Sent         int64  `json:"sent"`
+	Failed       int64  `json:"failed"` // 失败的发送尝试次数（含之后重试成功的）
+	DeadLettered int64  `json:"deadLettered"`
+	RateLimited  int64  `json:"rateLimited"` // 因限流而等待的次数
+	Suppressed   int64  `json:"suppressed"`  // 因限流被合并进汇总消息的告警条数
+}
+
+// Dispatcher 为每个渠道维护一个发送队列，失败时按指数退避（带抖动）重试，
+// 超过最大次数或遇到不可重试的错误时转入死信列表。配置了 spoolDir 时队列会落盘，重启后继续发送。
+// 每个渠道可以配置令牌桶限流，超出时排队等待或合并为汇总消息，不会静默丢弃。
+type Dispatcher struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	sendTimeout    time.Duration
+	deadLetterSize int
+	spool          *spool
+	history        *History
+
+	queues map[string]*queue
+	names  []string
+
+	mu          sync.Mutex
+	deadLetters []Message
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+type queue struct {
+	notifier Notifier
+	limiter  *limiter
+	mu       sync.Mutex
+	pending  []*Message
+	stats    ChannelStats
+	wake     chan struct{}
+}
+
+func NewDispatcher(notifiers []Notifier, cfg config.DispatchConfig) (*Dispatcher, error) {
+	d := &Dispatcher{
+		maxAttempts:    cfg.MaxAttempts,
+		initialBackoff: parseDurationDefault(cfg.InitialBackoff, 5*time.Second),
+		maxBackoff:     parseDurationDefault(cfg.MaxBackoff, 10*time.Minute),
+		sendTimeout:    parseDurationDefault(cfg.SendTimeout, 30*time.Second),
+		deadLetterSize: cfg.DeadLetterSize,
+		queues:         make(map[string]*queue),
+		stop:           make(chan struct{}),
+	}
+	if d.maxAttempts <= 0 {
+		d.maxAttempts = 8
+	}
+	if d.deadLetterSize <= 0 {
+		d.deadLetterSize = 1000
+	}
+	for _, n := range notifiers {
+		l, err := newLimiter(n.Name(), cfg.RateLimits)
+		if err != nil {
+			return nil, err
+		}
+		// 汇总消息没有对应的告警标识，发给按告警生命周期处理的渠道会更新或恢复错误的事件
+		if rn, ok := n.(ResolveNotifier); ok && rn.WantsResolved() && l != nil && l.summarize {
+			return nil, fmt.Errorf("rateLimits.%s: overflow summarize is not supported for channels that track alert lifecycle", n.Name())
+		}
+		d.queues[n.Name()] = &queue{
+			notifier: n,
+			limiter:  l,
+			stats:    ChannelStats{Channel: n.Name()},
+			wake:     make(chan struct{}, 1),
+		}
+		d.names = append(d.names, n.Name())
+	}
+	if cfg.SpoolDir == "" {
+		d.history = newHistory(cfg.HistorySize, "")
+		return d, nil
+	}
+
+	sp, err := newSpool(cfg.SpoolDir)
+	if err != nil {
+		return nil, err
+	}
+	d.spool = sp
+	d.history = newHistory(cfg.HistorySize, cfg.SpoolDir)
+	if d.deadLetters, err = sp.loadDeadLetters(); err != nil {
+		return nil, err
+	}
+	pending, err := sp.load()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range pending {
+		q, ok := d.queues[m.Channel]
+		if !ok {
+			m.LastError = fmt.Sprintf("渠道 %s 已不存在", m.Channel)
+			d.deadLetter(m)
+			continue
+		}
+		q.push(m)
+		q.count(func(s *ChannelStats) { s.Enqueued++ })
+	}
+	if len(pending) > 0 {
+		logging.Infof("从落盘目录恢复待发送通知 %d 条", len(pending))
+	}
+	return d, nil
+}
+
+// Start 为每个渠道启动发送协程
+func (d *Dispatcher) Start() {
+	for _, q := range d.queues {
+		d.wg.Add(1)
+		go d.run(q)
+	}
+}
+
+// Stop 停止发送协程，未发送的通知保留在落盘目录中
+func (d *Dispatcher) Stop() {
+	close(d.stop)
+	d.wg.Wait()
+}
+
+// Has 判断渠道是否已配置
+func (d *Dispatcher) Has(channel string) bool {
+	_, ok := d.queues[channel]
+	return ok
+}
+
+// WantsResolved 判断渠道是否需要接收所有恢复通知
+func (d *Dispatcher) WantsResolved(channel string) bool {
+	q, ok := d.queues[channel]
+	if !ok {
+		return false
+	}
+	rn, ok := q.notifier.(ResolveNotifier)
+	return ok && rn.WantsResolved()
+}
+
+// WantsAcked 判断渠道是否需要接收告警确认事件
+func (d *Dispatcher) WantsAcked(channel string) bool {
+	q, ok := d.queues[channel]
+	if !ok {
+		return false
+	}
+	an, ok := q.notifier.(AckNotifier)
+	return ok && an.WantsAcked()
+}
+
+// WantsRepeat 判断渠道是否需要在告警持续期间的每次评估都收到通知
+func (d *Dispatcher) WantsRepeat(channel string) bool {
+	q, ok := d.queues[channel]
+	if !ok {
+		return false
+	}
+	rn, ok := q.notifier.(RepeatNotifier)
+	return ok && rn.WantsRepeat()
+}
+
+// Accepts 判断渠道是否接收该事件，渠道不存在时返回 true，由 Enqueue 报错
+func (d *Dispatcher) Accepts(channel string, ev *AlertEvent) bool {
+	q, ok := d.queues[channel]
+	if !ok {
+		return true
+	}
+	fn, ok := q.notifier.(FilterNotifier)
+	return !ok || fn.Accepts(ev)
+}
+
+// TakesAction 判断渠道是否会触发动作（执行命令、呼叫值班人员等）
+func (d *Dispatcher) TakesAction(channel string) bool {
+	q, ok := d.queues[channel]
+	if !ok {
+		return false
+	}
+	an, ok := q.notifier.(ActionNotifier)
+	return ok && an.TakesAction()
+}
+
+// Enqueue 将一条告警事件放入渠道队列，立即返回。事件入队后不应再被修改（渠道记录的 Delivered 除外）。
+func (d *Dispatcher) Enqueue(channel string, ev *AlertEvent) error {
+	q, ok := d.queues[channel]
+	if !ok {
+		return fmt.Errorf("渠道 %s 未配置", channel)
+	}
+	now := time.Now()
+	m := &Message{
+		ID:        newMessageID(now),
+		Channel:   channel,
+		Rule:      ev.Rule,
+		Event:     ev,
+		CreatedAt: now,
+		NextAt:    now,
+	}
+	if err := d.spool.save(m); err != nil {
+		logging.Errorf("通知落盘失败（仍会尝试发送）: %v", err)
+	}
+	q.push(m)
+	q.count(func(s *ChannelStats) { s.Enqueued++ })
+	return nil
+}
+
+// History 返回发送历史
+func (d *Dispatcher) History() *History {
+	return d.history
+}
+
+// Stats 返回各渠道的发送统计
+func (d *Dispatcher) Stats() []ChannelStats {
+	out := make([]ChannelStats, 0, len(d.names))
+	for _, name := range d.names {
+		q := d.queues[name]
+		q.mu.Lock()
+		s := q.stats
+		s.Queued = len(q.pending)
+		q.mu.Unlock()
+		out = append(out, s)
+	}
+	return out
+}
+
+// DeadLetters 返回死信列表（最新的在前）
+func (d *Dispatcher) DeadLetters() []Message {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]Message, len(d.deadLetters))
+	for i, m := range d.deadLetters {
+		out[len(out)-1-i] = m
+	}
+	return out
+}
+
+// Retry 将一条死信重新放回发送队列
+func (d *Dispatcher) Retry(id string) error {
+	d.mu.Lock()
+	var msg *Message
+	for i, m := range d.deadLetters {
+		if m.ID == id {
+			msg = &m
+			d.deadLetters = append(d.deadLetters[:i], d.deadLetters[i+1:]...)
+			break
+		}
+	}
+	dead := append([]Message(nil), d.deadLetters...)
+	d.mu.Unlock()
+	if msg == nil {
+		return fmt.Errorf("死信 %s 不存在", id)
+	}
+	q, ok := d.queues[msg.Channel]
+	if !ok {
+		return fmt.Errorf("渠道 %s 未配置", msg.Channel)
+	}
+	if err := d.spool.saveDeadLetters(dead); err != nil {
+		logging.Errorf("死信落盘失败: %v", err)
+	}
+	msg.Attempts = 0
+	msg.NextAt = time.Now()
+	msg.LastError = ""
+	if err := d.spool.save(msg); err != nil {
+		logging.Errorf("通知落盘失败（仍会尝试发送）: %v", err)
+	}
+	q.push(msg)
+	return nil
+}
+
+func (d *Dispatcher) run(q *queue) {
+	defer d.wg.Done()
+	for {
+		msg := q.next()
+		if msg == nil {
+			select {
+			case <-q.wake:
+				continue
+			case <-d.stop:
+				return
+			}
+		}
+		// 等待重试时间期间有新通知入队需要重新挑选，等待限流令牌时则不需要
+		wake := q.wake
+		wait := time.Until(msg.NextAt)
+		if wait <= 0 && q.limiter != nil {
+			if wait = q.limiter.take(time.Now(), q.cost(msg.Event)); wait > 0 {
+				wake = nil
+				q.count(func(s *ChannelStats) { s.RateLimited++ })
+				if q.limiter.summarize {
+					d.collapse(q, msg)
+				}
+			}
+		}
+		if wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-wake:
+			case <-d.stop:
+				return
+			}
+			continue
+		}
+		d.deliver(q, msg)
+	}
+}
+
+// deliver 发送一条通知；失败且可重试时推迟 NextAt，等待重试期间同一渠道的其他通知照常发送
+func (d *Dispatcher) deliver(q *queue, msg *Message) {
+	msg.Attempts++
+	var out actionOutput
+	ctx, cancel := context.WithTimeout(withActionOutput(context.Background(), &out), d.sendTimeout)
+	err := q.notifier.Send(ctx, msg.Event)
+	cancel()
+	entry := HistoryEntry{
+		Time:        time.Now(),
+		Rule:        msg.Rule,
+		Channel:     msg.Channel,
+		Status:      msg.Event.Status,
+		Fingerprint: msg.Event.Fingerprint,
+		Title:       msg.Event.Title,
+		Count:       msg.Event.Count,
+		Result:      HistorySent,
+		Attempt:     msg.Attempts,
+		Output:      out.text,
+	}
+	if !msg.Event.WindowStart.IsZero() {
+		start, end := msg.Event.WindowStart, msg.Event.WindowEnd
+		entry.WindowStart, entry.WindowEnd = &start, &end
+	}
+	if err == nil {
+		d.history.Add(entry)
+		q.remove(msg)
+		q.count(func(s *ChannelStats) { s.Sent++ })
+		d.spool.remove(msg.ID)
+		logging.Debugf("通过渠道 %s 发送告警成功: 规则=%s 尝试次数=%d", msg.Channel, msg.Rule, msg.Attempts)
+		return
+	}
+
+	msg.LastError = err.Error()
+	q.count(func(s *ChannelStats) { s.Failed++ })
+	retryable, after := classify(err)
+	entry.Error = err.Error()
+	if !retryable || msg.Attempts >= d.maxAttempts {
+		entry.Result = HistoryFailed
+		d.history.Add(entry)
+		q.remove(msg)
+		q.count(func(s *ChannelStats) { s.DeadLettered++ })
+		d.spool.remove(msg.ID)
+		d.deadLetter(msg)
+		logging.Errorf("通过渠道 %s 发送告警失败，已转入死信: 规则=%s 尝试次数=%d 错误=%v", msg.Channel, msg.Rule, msg.Attempts, err)
+		// 同一告警之后的通知（如恢复）单独送达会让事件状态错乱，随之一起转入死信，重新发送时按顺序处理
+		if fp := msg.Event.Fingerprint; fp != "" {
+			for _, m := range q.removeFingerprint(fp) {
+				m.LastError = fmt.Sprintf("同一告警的前序通知 %s 已转入死信", msg.ID)
+				q.count(func(s *ChannelStats) { s.DeadLettered++ })
+				d.spool.remove(m.ID)
+				d.deadLetter(m)
+			}
+		}
+		return
+	}
+	entry.Result = HistoryRetrying
+	d.history.Add(entry)
+	wait := d.backoff(msg.Attempts, after)
+	msg.NextAt = time.Now().Add(wait)
+	if err := d.spool.save(msg); err != nil {
+		logging.Errorf("通知落盘失败: %v", err)
+	}
+	logging.Errorf("通过渠道 %s 发送告警失败，%s 后重试: 规则=%s 尝试次数=%d 错误=%v", msg.Channel, wait.Round(time.Second), msg.Rule, msg.Attempts, err)
+}
+
+// collapse 在限流且积压过多时，将即将发送的 current 之外、尚未尝试发送的告警通知合并为一条汇总消息。
+// 恢复 / 确认通知与正在重试的通知原样保留，避免丢失告警状态的变化。
+func (d *Dispatcher) collapse(q *queue, current *Message) {
+	q.mu.Lock()
+	var merge, keep []*Message
+	for _, m := range q.pending {
+		switch {
+		case m == current:
+		case m.Attempts == 0 && (m.Event.Status == StatusFiring || m.Event.Status == ""):
+			merge = append(merge, m)
+		default:
+			keep = append(keep, m)
+		}
+	}
+	if len(merge) <= q.limiter.summarizeAfter {
+		q.mu.Unlock()
+		return
+	}
+	summary := summarizeMessages(q.stats.Channel, merge)
+	q.pending = append([]*Message{current, summary}, keep...)
+	suppressed := 0
+	for _, m := range merge {
+		if m.Merged != nil {
+			for _, n := range m.Merged {
+				suppressed += n
+			}
+		} else {
+			suppressed++
+		}
+	}
+	q.stats.Suppressed += int64(suppressed)
+	q.mu.Unlock()
+
+	for _, m := range merge {
+		d.spool.remove(m.ID)
+	}
+	if err := d.spool.save(summary); err != nil {
+		logging.Errorf("通知落盘失败: %v", err)
+	}
+	logging.Errorf("渠道 %s 触发限流，%d 条积压告警已合并为汇总消息", q.stats.Channel, suppressed)
+}
+
+func (d *Dispatcher) deadLetter(msg *Message) {
+	d.mu.Lock()
+	d.deadLetters = append(d.deadLetters, *msg)
+	if len(d.deadLetters) > d.deadLetterSize {
+		d.deadLetters = d.deadLetters[len(d.deadLetters)-d.deadLetterSize:]
+	}
+	dead := append([]Message(nil), d.deadLetters...)
+	d.mu.Unlock()
+	if err := d.spool.saveDeadLetters(dead); err != nil {
+		logging.Errorf("死信落盘失败: %v", err)
+	}
+}
+
+// backoff 计算第 attempt 次失败后的等待时间：指数增长并加入 50% 抖动，且不短于渠道要求的 after
+func (d *Dispatcher) backoff(attempt int, after time.Duration) time.Duration {
+	b := d.initialBackoff
+	for i := 1; i < attempt && b < d.maxBackoff; i++ {
+		b *= 2
+	}
+	if b > d.maxBackoff {
+		b = d.maxBackoff
+	}
+	b = b/2 + time.Duration(mrand.Int63n(int64(b/2)+1))
+	if after > b {
+		b = after
+	}
+	return b
+}
+
+func (q *queue) push(m *Message) {
+	q.mu.Lock()
+	q.pending = append(q.pending, m)
+	q.mu.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *queue) count(f func(s *ChannelStats)) {
+	q.mu.Lock()
+	f(&q.stats)
+	q.mu.Unlock()
+}
+
+// cost 返回发送事件消耗的限流令牌数
+func (q *queue) cost(ev *AlertEvent) int {
+	if cn, ok := q.notifier.(CostNotifier); ok {
+		return max(cn.Cost(ev), 1)
+	}
+	return 1
+}
+
+// next 返回最早可以发送的通知（NextAt 最早，相同时按入队顺序）。同一告警（指纹相同）的通知按入队顺序逐条发送：
+// 较早的通知还在排队或等待重试时，之后的通知不会被选中，避免恢复先于触发送达而让事件一直无法关闭
+func (q *queue) next() *Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var next *Message
+	blocked := make(map[string]bool)
+	for _, m := range q.pending {
+		if fp := m.Event.Fingerprint; fp != "" {
+			if blocked[fp] {
+				continue
+			}
+			blocked[fp] = true
+		}
+		if next == nil || m.NextAt.Before(next.NextAt) {
+			next = m
+		}
+	}
+	return next
+}
+
+// removeFingerprint 移出排队中指纹为 fp 的所有通知
+func (q *queue) removeFingerprint(fp string) []*Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var removed []*Message
+	kept := q.pending[:0]
+	for _, m := range q.pending {
+		if m.Event.Fingerprint == fp {
+			removed = append(removed, m)
+			continue
+		}
+		kept = append(kept, m)
+	}
+	q.pending = kept
+	return removed
+}
+
+func (q *queue) remove(msg *Message) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, m := range q.pending {
+		if m == msg {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+func newMessageID(now time.Time) string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%d-%s", now.UnixNano(), hex.EncodeToString(b))
+}
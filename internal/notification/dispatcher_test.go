@@ -0,0 +1,146 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"elasticsearch-alert/internal/config"
+)
+
+// recordingNotifier 记录每次发送的事件状态，fail 决定某次发送返回的错误
+type recordingNotifier struct {
+	mu    sync.Mutex
+	sends []string
+	fail  func(n int, ev *AlertEvent) error
+	// gate 非 nil 时，第一次发送等待 gate 关闭后才返回
+	gate chan struct{}
+	done chan struct{}
+}
+
+func (n *recordingNotifier) Name() string { return "recorder" }
+
+func (n *recordingNotifier) Send(ctx context.Context, ev *AlertEvent) error {
+	n.mu.Lock()
+	n.sends = append(n.sends, ev.Status)
+	count := len(n.sends)
+	n.mu.Unlock()
+	if count == 1 && n.gate != nil {
+		<-n.gate
+	}
+	var err error
+	if n.fail != nil {
+		err = n.fail(count, ev)
+	}
+	n.done <- struct{}{}
+	return err
+}
+
+func (n *recordingNotifier) sent() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string(nil), n.sends...)
+}
+
+func newTestDispatcher(t *testing.T, n Notifier) *Dispatcher {
+	t.Helper()
+	d, err := NewDispatcher([]Notifier{n}, config.DispatchConfig{InitialBackoff: "100ms", MaxBackoff: "200ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Start()
+	t.Cleanup(d.Stop)
+	return d
+}
+
+func waitSends(t *testing.T, n *recordingNotifier, count int) {
+	t.Helper()
+	for i := 0; i < count; i++ {
+		select {
+		case <-n.done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for send %d, got %v", i+1, n.sent())
+		}
+	}
+}
+
+func lifecycleEvent(status string) *AlertEvent {
+	return &AlertEvent{Rule: "k8s-error", Status: status, Fingerprint: "esalert-0123456789abcdef"}
+}
+
+func TestDispatcherKeepsOrderPerFingerprint(t *testing.T) {
+	n := &recordingNotifier{
+		done: make(chan struct{}, 10),
+		fail: func(count int, ev *AlertEvent) error {
+			if count == 1 {
+				return Temporary(errors.New("temporarily unavailable"), 0)
+			}
+			return nil
+		},
+	}
+	d := newTestDispatcher(t, n)
+
+	if err := d.Enqueue("recorder", lifecycleEvent(StatusFiring)); err != nil {
+		t.Fatal(err)
+	}
+	waitSends(t, n, 1)
+	// 触发通知等待重试期间到达的恢复通知必须排在它之后，其他告警不受影响
+	if err := d.Enqueue("recorder", lifecycleEvent(StatusResolved)); err != nil {
+		t.Fatal(err)
+	}
+	other := lifecycleEvent(StatusAcked)
+	other.Fingerprint = "esalert-fedcba9876543210"
+	if err := d.Enqueue("recorder", other); err != nil {
+		t.Fatal(err)
+	}
+	waitSends(t, n, 3)
+
+	want := []string{StatusFiring, StatusAcked, StatusFiring, StatusResolved}
+	got := n.sent()
+	if len(got) != len(want) {
+		t.Fatalf("sends = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("sends = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestDispatcherDeadLettersSuccessors(t *testing.T) {
+	n := &recordingNotifier{
+		done: make(chan struct{}, 10),
+		gate: make(chan struct{}),
+		fail: func(count int, ev *AlertEvent) error {
+			return Permanent(errors.New("invalid routing key"))
+		},
+	}
+	d := newTestDispatcher(t, n)
+
+	if err := d.Enqueue("recorder", lifecycleEvent(StatusFiring)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Enqueue("recorder", lifecycleEvent(StatusResolved)); err != nil {
+		t.Fatal(err)
+	}
+	close(n.gate)
+	waitSends(t, n, 1)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(d.DeadLetters()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	dead := d.DeadLetters()
+	if len(dead) != 2 {
+		t.Fatalf("got %d dead letters, want the failed firing and its resolved successor", len(dead))
+	}
+	// 最新的在前
+	if dead[0].Event.Status != StatusResolved || dead[1].Event.Status != StatusFiring {
+		t.Errorf("dead letters = %s, %s", dead[0].Event.Status, dead[1].Event.Status)
+	}
+	if got := n.sent(); len(got) != 1 {
+		t.Errorf("sends = %v, the resolved event must not be delivered after its firing failed", got)
+	}
+}
@@ -2,138 +2,160 @@ package notification
 
 import (
 	"context"
-	"crypto/tls"
+	"errors"
 	"fmt"
-	"html"
-	"net"
 	"net/smtp"
+	"net/textproto"
 	"strings"
 	"time"
+
+	"elasticsearch-alert/internal/config"
 )
 
+// EmailNotifier 通过 SMTP 发送告警邮件，连接在多封邮件间复用（见 smtpPool）
 type EmailNotifier struct {
-	Host          string
-	Port          int
-	Username      string
-	Password      string
-	From          string
-	To            []string
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	To       []string
+	Cc       []string
+	Bcc      []string
+	// Groups 收件人组，规则通过 alerts.email.groups 引用
+	Groups map[string]config.EmailRecipientGroup
+	// Attachment 为 csv / ndjson 时将样例文档作为附件
+	Attachment    string
 	UseTLS        bool
 	TLSSkipVerify bool
+	StartTLS      string
+	AuthMechanism string
 	SubjectPrefix string
 	Timeout       time.Duration
+
+	pool *smtpPool
+}
+
+// NewEmailNotifier 根据配置创建邮件渠道
+func NewEmailNotifier(cfg config.EmailConfig) (*EmailNotifier, error) {
+	e := &EmailNotifier{
+		Host:          cfg.Host,
+		Port:          cfg.Port,
+		Username:      cfg.Username,
+		Password:      cfg.Password,
+		From:          cfg.From,
+		To:            cfg.To,
+		Cc:            cfg.Cc,
+		Bcc:           cfg.Bcc,
+		Groups:        cfg.RecipientGroups,
+		Attachment:    cfg.Attachment,
+		UseTLS:        cfg.UseTLS,
+		TLSSkipVerify: cfg.TLSSkipVerify,
+		StartTLS:      strings.ToLower(cfg.StartTLS),
+		AuthMechanism: cfg.AuthMechanism,
+		SubjectPrefix: cfg.SubjectPrefix,
+		Timeout:       parseDurationDefault(cfg.Timeout, 10*time.Second),
+	}
+	switch e.StartTLS {
+	case "":
+		e.StartTLS = StartTLSOpportunistic
+	case StartTLSOpportunistic, StartTLSRequired, StartTLSNone:
+	default:
+		return nil, fmt.Errorf("email: unknown startTLS policy %q", cfg.StartTLS)
+	}
+	maxIdle := cfg.MaxIdleConns
+	if maxIdle <= 0 {
+		maxIdle = 2
+	}
+	e.pool = &smtpPool{
+		dial:        e.dialSMTP,
+		maxIdle:     maxIdle,
+		idleTimeout: parseDurationDefault(cfg.IdleTimeout, time.Minute),
+		timeout:     e.Timeout,
+	}
+	return e, nil
 }
 
 func (e *EmailNotifier) Name() string { return "email" }
 
-func (e *EmailNotifier) Send(ctx context.Context, title, text string) error {
-	subject := title
+func (e *EmailNotifier) Send(ctx context.Context, ev *AlertEvent) error {
+	subject := ev.Title
 	if e.SubjectPrefix != "" {
-		subject = e.SubjectPrefix + " " + title
+		subject = e.SubjectPrefix + " " + ev.Title
 	}
-	msg := buildEmailMessage(e.From, e.To, subject, text)
-	addr := fmt.Sprintf("%s:%d", e.Host, e.Port)
-	auth := smtp.PlainAuth("", e.Username, e.Password, e.Host)
-
-	dialer := net.Dialer{Timeout: e.Timeout}
-	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	to, cc, bcc, err := e.recipients(ev)
 	if err != nil {
 		return err
 	}
-	defer conn.Close()
+	if len(to)+len(cc)+len(bcc) == 0 {
+		return Permanent(fmt.Errorf("email: no recipients"))
+	}
+	msg := &emailMessage{
+		From:    e.From,
+		To:      to,
+		Cc:      cc,
+		Subject: subject,
+		Text:    emailText(ev),
+		HTML:    emailHTML(subject, ev),
+	}
+	if a, ok := sampleAttachment(e.Attachment, ev); ok {
+		msg.Attachments = append(msg.Attachments, a)
+	}
+	// 信封收件人包含 Cc 与 Bcc，Bcc 不出现在邮件头中
+	var rcpts []string
+	for _, a := range mergeUnique(to, cc, bcc) {
+		rcpts = append(rcpts, envelopeAddress(a))
+	}
+	data := msg.Bytes()
 
-	if e.UseTLS {
-		tlsCfg := &tls.Config{
-			ServerName:         e.Host,
-			InsecureSkipVerify: e.TLSSkipVerify,
-		}
-		tlsConn := tls.Client(conn, tlsCfg)
-		if err := tlsConn.Handshake(); err != nil {
-			return err
-		}
-		c, err := smtp.NewClient(tlsConn, e.Host)
+	for {
+		c, reused, err := e.pool.get(ctx)
 		if err != nil {
 			return err
 		}
-		defer c.Quit()
-		if e.Username != "" {
-			if err := c.Auth(auth); err != nil {
-				return err
-			}
+		c.conn.SetDeadline(time.Now().Add(e.Timeout))
+		err = sendMail(c.client, envelopeAddress(e.From), rcpts, data)
+		if err == nil {
+			e.pool.put(c)
+			return nil
 		}
-		if err := sendMail(c, e.From, e.To, []byte(msg)); err != nil {
+		var te *textproto.Error
+		if errors.As(err, &te) {
+			// 服务器明确拒绝（如收件人不存在），连接本身仍可用；5xx 为永久错误，直接转入死信
+			e.pool.put(c)
+			if te.Code >= 500 {
+				return Permanent(err)
+			}
 			return err
 		}
-		return nil
-	}
-
-	// STARTTLS path
-	c, err := smtp.NewClient(conn, e.Host)
-	if err != nil {
-		return err
-	}
-	defer c.Quit()
-
-	if ok, _ := c.Extension("STARTTLS"); ok {
-		tlsCfg := &tls.Config{
-			ServerName:         e.Host,
-			InsecureSkipVerify: e.TLSSkipVerify,
-		}
-		if err := c.StartTLS(tlsCfg); err != nil {
+		c.close()
+		// 复用的连接可能已被服务器关闭，换一个新连接重试；新连接失败则交给分发器重试
+		if !reused {
 			return err
 		}
 	}
-	if e.Username != "" {
-		if err := c.Auth(auth); err != nil {
-			return err
+}
+
+// recipients 合并渠道默认收件人、规则引用的收件人组与规则中的地址，以及值班人员
+func (e *EmailNotifier) recipients(ev *AlertEvent) (to, cc, bcc []string, err error) {
+	r := ev.Email
+	if r == nil || !r.Override {
+		to, cc, bcc = e.To, e.Cc, e.Bcc
+	}
+	if r != nil {
+		for _, name := range r.Groups {
+			g, ok := e.Groups[name]
+			if !ok {
+				return nil, nil, nil, Permanent(fmt.Errorf("email: unknown recipient group %q", name))
+			}
+			to, cc, bcc = mergeUnique(to, g.To), mergeUnique(cc, g.Cc), mergeUnique(bcc, g.Bcc)
 		}
+		to, cc, bcc = mergeUnique(to, r.To), mergeUnique(cc, r.Cc), mergeUnique(bcc, r.Bcc)
 	}
-	if err := sendMail(c, e.From, e.To, []byte(msg)); err != nil {
-		return err
+	for _, rc := range ev.Recipients {
+		to = mergeUnique(to, []string{rc.Email})
 	}
-	return nil
-}
-
-func buildEmailMessage(from string, to []string, subject, body string) string {
-	// 参考 opensearch-alert-main，将邮件内容美化为简单的 HTML 卡片，并支持少量 Markdown（**加粗**、换行）
-	formattedBody := markdownToHTML(body)
-	htmlBody := fmt.Sprintf(`
-<!DOCTYPE html>
-<html>
-<head>
-  <meta charset="UTF-8">
-  <title>%s</title>
-  <style>
-    body { font-family: -apple-system,BlinkMacSystemFont,Segoe UI,Roboto,Helvetica,Arial,sans-serif; margin: 20px; color: #333; }
-    .card { border-radius: 10px; border: 1px solid #f5c6cb; background-color: #fdecea; padding: 16px 20px; margin-bottom: 20px; }
-    .card h2 { margin: 0 0 8px 0; }
-    .content { background: #f8f9fa; border-radius: 6px; padding: 12px 16px; white-space: pre-wrap; font-family: Menlo,Consolas,monospace; }
-  </style>
-</head>
-<body>
-  <div class="card">
-    <h2>🚨 Elasticsearch 日志告警</h2>
-    <div>%s</div>
-  </div>
-  <div class="content">%s</div>
-</body>
-</html>
-`, html.EscapeString(subject), html.EscapeString(subject), formattedBody)
-
-	headers := map[string]string{
-		"From":         from,
-		"To":           strings.Join(to, ", "),
-		"Subject":      subject,
-		"MIME-Version": "1.0",
-		"Content-Type": "text/html; charset=UTF-8",
-	}
-	var sb strings.Builder
-	for k, v := range headers {
-		sb.WriteString(fmt.Sprintf("%s: %s\r\n", k, v))
-	}
-	sb.WriteString("\r\n")
-	sb.WriteString(htmlBody)
-	return sb.String()
+	return to, cc, bcc, nil
 }
 
 // markdownToHTML 将非常简单的 Markdown（**加粗**、\n 换行）转换为 HTML 片段
@@ -194,5 +216,3 @@ func sendMail(c *smtp.Client, from string, to []string, msg []byte) error {
 	}
 	return nil
 }
-
-
@@ -0,0 +1,71 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+// SendError 描述一次发送失败。Retryable 为 true 时分发器会按退避策略重试，
+// RetryAfter 为渠道明确要求的最短等待时间（如限流）。
+type SendError struct {
+	Err        error
+	Retryable  bool
+	RetryAfter time.Duration
+}
+
+func (e *SendError) Error() string { return e.Err.Error() }
+func (e *SendError) Unwrap() error { return e.Err }
+
+// Permanent 标记不可重试的错误（如配置错误、鉴权失败）
+func Permanent(err error) error {
+	return &SendError{Err: err}
+}
+
+// Temporary 标记可重试的错误，after 为建议的最短等待时间，可为 0
+func Temporary(err error, after time.Duration) error {
+	return &SendError{Err: err, Retryable: true, RetryAfter: after}
+}
+
+// classify 判断错误是否可以重试。未标记的错误（网络错误、超时等）一律视为可重试，避免丢失告警。
+func classify(err error) (bool, time.Duration) {
+	var se *SendError
+	if errors.As(err, &se) {
+		return se.Retryable, se.RetryAfter
+	}
+	var te *textproto.Error
+	if errors.As(err, &te) {
+		// SMTP 4xx 为临时错误，5xx 为永久错误
+		return te.Code < 500, 0
+	}
+	return true, 0
+}
+
+// statusError 根据 HTTP 状态码构造错误：429 与 5xx 可重试，其余 4xx 不可重试
+func statusError(channel string, resp *http.Response, body []byte) error {
+	err := fmt.Errorf("%s status=%d body=%s", channel, resp.StatusCode, string(body))
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return Temporary(err, retryAfter(resp.Header.Get("Retry-After")))
+	case resp.StatusCode >= 500:
+		return Temporary(err, 0)
+	default:
+		return Permanent(err)
+	}
+}
+
+func retryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
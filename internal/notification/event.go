@@ -0,0 +1,182 @@
+package notification
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Recipient 是单次告警需要额外送达或提醒的具体人员（如当前值班人员）
+type Recipient struct {
+	Name           string `json:"name"`
+	Email          string `json:"email,omitempty"`
+	Phone          string `json:"phone,omitempty"`
+	DingTalkMobile string `json:"dingtalkMobile,omitempty"`
+	DingTalkUserID string `json:"dingtalkUserId,omitempty"`
+	FeishuUserID   string `json:"feishuUserId,omitempty"`
+	WeChatUserID   string `json:"wechatUserId,omitempty"`
+}
+
+// Mentions 是规则级别的 @ 提醒配置，会与渠道配置中的提醒列表合并
+type Mentions struct {
+	DingTalkMobiles []string `yaml:"dingtalkMobiles" json:"dingtalkMobiles,omitempty"`
+	DingTalkUserIDs []string `yaml:"dingtalkUserIds" json:"dingtalkUserIds,omitempty"`
+	WeChatUserIDs   []string `yaml:"wechatUserIds" json:"wechatUserIds,omitempty"`
+	WeChatMobiles   []string `yaml:"wechatMobiles" json:"wechatMobiles,omitempty"`
+	FeishuUserIDs   []string `yaml:"feishuUserIds" json:"feishuUserIds,omitempty"`
+}
+
+// EmailRecipients 是规则级别的邮件收件人：Groups 引用邮件渠道配置中的 recipientGroups，
+// 与 To / Cc / Bcc 一起追加到渠道的默认收件人；Override 为 true 时不再发送给默认收件人
+type EmailRecipients struct {
+	Groups   []string `yaml:"groups" json:"groups,omitempty"`
+	To       []string `yaml:"to" json:"to,omitempty"`
+	Cc       []string `yaml:"cc" json:"cc,omitempty"`
+	Bcc      []string `yaml:"bcc" json:"bcc,omitempty"`
+	Override bool     `yaml:"override" json:"override,omitempty"`
+}
+
+// Field 是告警正文中的一项键值（如 规则名称 / 命中条数）
+type Field struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Summary 是告警正文的结构化内容（概览字段、目标、代表性日志与链接），
+// 与 AlertEvent.Text 中的 Markdown 正文一一对应。
+type Summary struct {
+	Description  string  `json:"description,omitempty"`
+	Overview     []Field `json:"overview,omitempty"`
+	Target       []Field `json:"target,omitempty"` // 代表性样例中的节点 / 命名空间 / Pod 等
+	Log          string  `json:"log,omitempty"`
+	LogTruncated bool    `json:"logTruncated,omitempty"`
+	DetailURL    string  `json:"detailURL,omitempty"`
+	RunbookURL   string  `json:"runbookURL,omitempty"`
+	// DiscoverURL Kibana / OpenSearch Dashboards Discover 链接，预置规则查询与告警时间窗
+	DiscoverURL string `json:"discoverURL,omitempty"`
+	// AckURL 告警确认页面，仅 firing 告警且配置了 web.baseURL 时存在
+	AckURL string `json:"ackURL,omitempty"`
+}
+
+// 告警状态
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+	StatusAcked    = "acked"
+)
+
+// statusIcon 返回标题前的状态图标
+func statusIcon(status string) string {
+	switch status {
+	case StatusResolved:
+		return "✅"
+	case StatusAcked:
+		return "👀"
+	}
+	return "🚨"
+}
+
+// mentionText 返回群机器人单独发送 @ 提醒时的文本
+func mentionText(ev *AlertEvent) string {
+	switch ev.Status {
+	case StatusResolved:
+		return fmt.Sprintf("%s %s，告警已恢复", statusIcon(ev.Status), ev.Title)
+	case StatusAcked:
+		return fmt.Sprintf("%s %s，告警已确认", statusIcon(ev.Status), ev.Title)
+	}
+	return fmt.Sprintf("%s %s，请及时处理", statusIcon(ev.Status), ev.Title)
+}
+
+// mentionable 判断事件是否需要 @ 提醒：只在告警触发时 @，恢复 / 确认时不再 @
+func mentionable(ev *AlertEvent) bool {
+	return ev.Status != StatusResolved && ev.Status != StatusAcked
+}
+
+// AlertEvent 是一次告警通知的结构化描述，由告警引擎生成并经分发器交给各渠道。
+// 能渲染原生布局的渠道（Slack / Teams / Webhook 等）直接使用其中的字段，
+// 简单的文本渠道只使用 Title 与渲染好的 Markdown 正文 Text（见 TextNotifier）。
+type AlertEvent struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+
+	Rule        string `json:"rule,omitempty"`
+	Description string `json:"description,omitempty"`
+	Index       string `json:"index,omitempty"`
+	Severity    string `json:"severity,omitempty"`
+	// Status 为 firing / resolved / acked
+	Status string `json:"status,omitempty"`
+	// Fingerprint 同一规则（及标签）的告警在 firing / resolved 间保持不变，用于下游去重
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Labels 规则配置的标签（本项目没有 group-by 查询，分组维度以静态标签表示）
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Count 本次评估的命中条数，Threshold 为规则阈值（countGt，未配置时为 nil）
+	Count      int              `json:"count"`
+	Threshold  *int             `json:"threshold,omitempty"`
+	TimeWindow string           `json:"timeWindow,omitempty"`
+	Samples    []map[string]any `json:"samples,omitempty"`
+	// WindowStart / WindowEnd 本次评估查询的绝对时间窗 [WindowStart, WindowEnd)
+	WindowStart time.Time `json:"windowStart"`
+	WindowEnd   time.Time `json:"windowEnd"`
+
+	// EvaluatedAt 本次规则评估时间，StartsAt / EndsAt 为本次告警的开始与恢复时间
+	EvaluatedAt time.Time `json:"evaluatedAt"`
+	StartsAt    time.Time `json:"startsAt"`
+	EndsAt      time.Time `json:"endsAt"`
+	// AckedAt / AckedBy 告警被确认的时间与确认人，未确认时为空
+	AckedAt time.Time `json:"ackedAt"`
+	AckedBy string    `json:"ackedBy,omitempty"`
+	// EvalInterval 规则相邻两次评估的间隔（由 cron 计算），0 表示未知
+	EvalInterval time.Duration `json:"evalInterval,omitempty"`
+
+	Recipients []Recipient `json:"recipients,omitempty"`
+	Mentions   Mentions    `json:"mentions,omitempty"`
+	Summary    Summary     `json:"summary"`
+	// SlackChannel 规则级别的 Slack 频道，覆盖渠道配置中的默认频道
+	SlackChannel string `json:"slackChannel,omitempty"`
+	// Email 规则级别的邮件收件人
+	Email *EmailRecipients `json:"email,omitempty"`
+
+	// Delivered 一次通知需要多次请求的渠道（如 Telegram 发送到多个会话）记录已送达的部分，
+	// 失败重试时跳过这些部分；随待发送通知一起落盘
+	Delivered []string `json:"delivered,omitempty"`
+}
+
+// delivered 判断多次请求中的某一部分是否已经送达
+func (ev *AlertEvent) delivered(key string) bool {
+	for _, k := range ev.Delivered {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// atAll 判断本次告警是否需要 @所有人：配置了 severities 时只对其中的级别生效，否则沿用 enable
+func atAll(enable bool, severities []string, severity string) bool {
+	if len(severities) == 0 {
+		return enable
+	}
+	for _, s := range severities {
+		if strings.EqualFold(s, severity) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeUnique 合并多个列表并去除空值与重复值，保持原有顺序
+func mergeUnique(lists ...[]string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, l := range lists {
+		for _, v := range l {
+			if v == "" || seen[v] {
+				continue
+			}
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
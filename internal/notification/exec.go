@@ -0,0 +1,188 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"elasticsearch-alert/internal/config"
+)
+
+const execOutputLimit = 64 * 1024
+
+// ExecNotifier 在告警触发时执行一条预先登记的命令（用于自动修复），渠道名称为 "exec:<名称>"。
+// 告警字段通过 ALERT_* 环境变量传入，完整的告警事件以 JSON 写入 stdin；
+// 命令的 stdout / stderr 会记录到发送历史中。
+// 修复动作不应被重复执行，因此命令失败或超时都不会重试，直接转入死信。
+type ExecNotifier struct {
+	Command    string
+	Args       []string
+	Dir        string
+	Env        map[string]string
+	Timeout    time.Duration
+	OnResolved bool
+
+	name string
+	// sem 在所有 exec 渠道间共享，限制同时执行的命令数
+	sem chan struct{}
+}
+
+// NewExecNotifiers 为每条登记的命令创建一个 exec 渠道
+func NewExecNotifiers(cfg config.ExecConfig) ([]Notifier, error) {
+	maxConcurrent := cfg.MaxConcurrent
+	if maxConcurrent <= 0 {
+		maxConcurrent = 2
+	}
+	sem := make(chan struct{}, maxConcurrent)
+	names := make([]string, 0, len(cfg.Commands))
+	for name := range cfg.Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var out []Notifier
+	for _, name := range names {
+		c := cfg.Commands[name]
+		if len(c.Command) == 0 || c.Command[0] == "" {
+			return nil, fmt.Errorf("exec command %q: command required", name)
+		}
+		out = append(out, &ExecNotifier{
+			Command:    c.Command[0],
+			Args:       c.Command[1:],
+			Dir:        c.Dir,
+			Env:        c.Env,
+			Timeout:    parseDurationDefault(c.Timeout, time.Minute),
+			OnResolved: c.OnResolved,
+			name:       "exec:" + name,
+			sem:        sem,
+		})
+	}
+	return out, nil
+}
+
+func (e *ExecNotifier) Name() string { return e.name }
+
+func (e *ExecNotifier) WantsResolved() bool { return e.OnResolved }
+
+func (e *ExecNotifier) TakesAction() bool { return true }
+
+func (e *ExecNotifier) Send(ctx context.Context, ev *AlertEvent) error {
+	switch ev.Status {
+	case StatusAcked:
+		return nil
+	case StatusResolved:
+		if !e.OnResolved {
+			return nil
+		}
+	}
+	stdin, err := json.Marshal(ev)
+	if err != nil {
+		return Permanent(err)
+	}
+
+	// 命令的超时独立于发送超时，等待并发名额的时间不计入命令超时
+	select {
+	case e.sem <- struct{}{}:
+	case <-ctx.Done():
+		return Temporary(fmt.Errorf("%s: waiting for concurrency slot: %w", e.name, ctx.Err()), 0)
+	}
+	defer func() { <-e.sem }()
+
+	runCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), e.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(runCtx, e.Command, e.Args...)
+	cmd.Dir = e.Dir
+	cmd.Env = append(os.Environ(), execEnv(ev)...)
+	for k, v := range e.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.Stdin = bytes.NewReader(stdin)
+	var output limitedBuffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// 子进程继承了输出管道时，超时后最多再等待 5 秒
+	cmd.WaitDelay = 5 * time.Second
+
+	start := time.Now()
+	err = cmd.Run()
+	recordOutput(ctx, output.String())
+	if err == nil {
+		return nil
+	}
+	if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		return Permanent(fmt.Errorf("%s: timed out after %s", e.name, e.Timeout))
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return Permanent(fmt.Errorf("%s: exit code %d after %s", e.name, exitErr.ExitCode(), time.Since(start).Round(time.Millisecond)))
+	}
+	return Permanent(fmt.Errorf("%s: %w", e.name, err))
+}
+
+var envKeyInvalid = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// execEnv 将告警字段转换为 ALERT_* 环境变量，标签为 ALERT_LABEL_<KEY>
+func execEnv(ev *AlertEvent) []string {
+	env := []string{
+		"ALERT_RULE=" + ev.Rule,
+		"ALERT_SEVERITY=" + ev.Severity,
+		"ALERT_STATUS=" + ev.Status,
+		"ALERT_FINGERPRINT=" + ev.Fingerprint,
+		"ALERT_INDEX=" + ev.Index,
+		"ALERT_TITLE=" + ev.Title,
+		"ALERT_COUNT=" + strconv.Itoa(ev.Count),
+		"ALERT_TIME_WINDOW=" + ev.TimeWindow,
+		"ALERT_DETAIL_URL=" + ev.Summary.DetailURL,
+		"ALERT_RUNBOOK_URL=" + ev.Summary.RunbookURL,
+		"ALERT_DISCOVER_URL=" + ev.Summary.DiscoverURL,
+	}
+	if ev.Threshold != nil {
+		env = append(env, "ALERT_THRESHOLD="+strconv.Itoa(*ev.Threshold))
+	}
+	if !ev.WindowStart.IsZero() {
+		env = append(env,
+			"ALERT_WINDOW_START="+ev.WindowStart.Format(time.RFC3339),
+			"ALERT_WINDOW_END="+ev.WindowEnd.Format(time.RFC3339),
+		)
+	}
+	if !ev.StartsAt.IsZero() {
+		env = append(env, "ALERT_STARTS_AT="+ev.StartsAt.Format(time.RFC3339))
+	}
+	for k, v := range ev.Labels {
+		env = append(env, "ALERT_LABEL_"+envKeyInvalid.ReplaceAllString(strings.ToUpper(k), "_")+"="+v)
+	}
+	return env
+}
+
+// limitedBuffer 只保留前 execOutputLimit 字节的输出
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := execOutputLimit - b.buf.Len(); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "\n...(输出过长，已截断)"
+	}
+	return b.buf.String()
+}
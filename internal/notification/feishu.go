@@ -3,75 +3,487 @@ package notification
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+
+	"elasticsearch-alert/internal/config"
+	"elasticsearch-alert/internal/logging"
+)
+
+const (
+	// feishuMaxBytes 卡片消息请求体上限约 30KB，为卡片结构与 @ 预留空间
+	feishuMaxBytes = 20000
+	feishuAPIURL   = "https://open.feishu.cn"
+	// feishuMessageTTL 应用机器人模式下保存消息 ID 的时长，超过后恢复时不再更新卡片
+	feishuMessageTTL = 7 * 24 * time.Hour
 )
 
-// 飞书简单文本卡片通知（支持 @all）
+// 飞书返回的错误码
+const (
+	feishuErrWebhookRateLimited = 11232
+	feishuErrAPIRateLimited     = 99991400
+)
+
+// 飞书 tenant_access_token 无效或过期的错误码
+var feishuErrTokenInvalid = []int{99991661, 99991663, 99991668}
+
+// FeishuNotifier 发送飞书交互式卡片（支持 @all 与 @指定用户）。
+// 配置 AppID 时使用应用机器人模式：通过 tenant_access_token 调用 im/v1/messages 发送到指定群聊 / 用户，
+// 告警恢复或被确认时原地更新已发送的卡片；否则使用群机器人 webhook（可选签名校验）。
 type FeishuNotifier struct {
-	Webhook      string
-	EnableAtAll  bool
-	Timeout      time.Duration
-	TitlePrefix  string
-	ContentIntro string
+	Webhook         string
+	Secret          string
+	EnableAtAll     bool
+	AtAllSeverities []string
+	AtUserIDs       []string
+	Timeout         time.Duration
+	TitlePrefix     string
+	ContentIntro    string
+	// MaxBytes 卡片正文的大小预算，超出时按优先级收缩内容
+	MaxBytes int
+
+	AppID            string
+	AppSecret        string
+	APIURL           string
+	Receivers        []config.FeishuReceiver
+	MessageStateFile string
+
+	token    *accessToken
+	mu       sync.Mutex
+	messages map[string]feishuMessages
+}
+
+// feishuMessages 记录某次告警（按指纹）发送过的卡片，用于恢复 / 确认时更新
+type feishuMessages struct {
+	IDs    []string  `json:"ids"`
+	PostAt time.Time `json:"postAt"`
+}
+
+// NewFeishuNotifier 根据配置创建飞书渠道
+func NewFeishuNotifier(cfg config.FeishuConfig) *FeishuNotifier {
+	f := &FeishuNotifier{
+		Webhook:          cfg.Webhook,
+		Secret:           cfg.Secret,
+		EnableAtAll:      cfg.EnableAtAll,
+		AtAllSeverities:  cfg.AtAllSeverities,
+		AtUserIDs:        cfg.AtUserIDs,
+		Timeout:          parseDurationDefault(cfg.Timeout, 5*time.Second),
+		TitlePrefix:      cfg.TitlePrefix,
+		ContentIntro:     cfg.ContentIntro,
+		MaxBytes:         cfg.MaxBytes,
+		AppID:            cfg.App.AppID,
+		AppSecret:        cfg.App.AppSecret,
+		APIURL:           cfg.App.APIURL,
+		Receivers:        cfg.App.Receivers,
+		MessageStateFile: cfg.App.MessageStateFile,
+	}
+	f.token = &accessToken{fetch: f.fetchToken}
+	return f
 }
 
 func (f *FeishuNotifier) Name() string { return "feishu" }
 
-func (f *FeishuNotifier) Send(ctx context.Context, title, text string) error {
-	displayTitle := title
+// WantsResolved 应用机器人模式下恢复时会更新原卡片，因此总是需要恢复事件
+func (f *FeishuNotifier) WantsResolved() bool { return f.AppID != "" }
+
+func (f *FeishuNotifier) WantsAcked() bool { return f.AppID != "" }
+
+func (f *FeishuNotifier) Send(ctx context.Context, ev *AlertEvent) error {
+	budget := maxBytesDefault(f.MaxBytes, feishuMaxBytes)
+	if f.ContentIntro != "" {
+		budget -= len(f.ContentIntro) + 2
+	}
+	card := f.card(fitEvent(ev, budget))
+	if f.AppID == "" {
+		return f.sendWebhook(ctx, card)
+	}
+	return f.sendApp(ctx, ev, card)
+}
+
+// card 渲染交互式卡片：按级别着色的标题、两列概览字段、可折叠的错误日志与跳转按钮
+func (f *FeishuNotifier) card(ev *AlertEvent) map[string]any {
+	sum := ev.Summary
+	title := ev.Title
 	if f.TitlePrefix != "" {
-		displayTitle = f.TitlePrefix + " " + title
+		title = f.TitlePrefix + " " + ev.Title
 	}
-	// 为飞书标题增加统一的告警 Emoji 前缀
-	displayTitle = "🚨 " + displayTitle
-	if f.ContentIntro != "" {
-		text = f.ContentIntro + "\n\n" + text
+	markdown := func(content string) map[string]any {
+		return map[string]any{"tag": "markdown", "content": content}
 	}
-	// @所有人放在消息最底部，更符合阅读习惯
-	if f.EnableAtAll {
-		text = text + "\n\n<at id=all></at>"
+
+	var elements []map[string]any
+	if f.ContentIntro != "" && ev.Status != StatusResolved && ev.Status != StatusAcked {
+		elements = append(elements, markdown(f.ContentIntro))
+	}
+	if len(sum.Overview) == 0 {
+		// 限流汇总等没有结构化内容的消息，直接展示正文
+		elements = append(elements, markdown(ev.Text))
+	} else {
+		if sum.Description != "" {
+			elements = append(elements, markdown(sum.Description))
+		}
+		elements = append(elements, feishuFields(sum.Overview))
+		if len(sum.Target) > 0 {
+			elements = append(elements, map[string]any{"tag": "hr"}, markdown("**📌 本次告警目标**"), feishuFields(sum.Target))
+		}
+		if sum.Log != "" {
+			log := sum.Log
+			if sum.LogTruncated {
+				log += "\n...(日志内容较长，已截断显示)"
+			}
+			elements = append(elements, map[string]any{
+				"tag":      "collapsible_panel",
+				"expanded": false,
+				"header": map[string]any{
+					"title": map[string]any{"tag": "markdown", "content": "**🧾 错误日志**"},
+				},
+				"elements": []map[string]any{markdown("```\n" + log + "\n```")},
+			})
+		}
 	}
 
-	// 使用交互式卡片样式，结构化展示内容
+	// @所有人 / @值班人员放在消息最底部，更符合阅读习惯；恢复 / 确认时不再 @
+	if ev.Status != StatusResolved && ev.Status != StatusAcked {
+		var mentions []string
+		if atAll(f.EnableAtAll, f.AtAllSeverities, ev.Severity) {
+			mentions = append(mentions, "<at id=all></at>")
+		}
+		var recipientIDs []string
+		for _, r := range ev.Recipients {
+			recipientIDs = append(recipientIDs, r.FeishuUserID)
+		}
+		for _, id := range mergeUnique(f.AtUserIDs, ev.Mentions.FeishuUserIDs, recipientIDs) {
+			mentions = append(mentions, fmt.Sprintf("<at id=%s></at>", id))
+		}
+		if len(mentions) > 0 {
+			elements = append(elements, markdown(strings.Join(mentions, " ")))
+		}
+	}
+
+	var actions []map[string]any
+	if sum.DetailURL != "" {
+		actions = append(actions, feishuButton("查看日志详情", sum.DetailURL, "primary"))
+	}
+	if sum.DiscoverURL != "" {
+		actions = append(actions, feishuButton("在 Discover 中查看", sum.DiscoverURL, "default"))
+	}
+	if sum.RunbookURL != "" {
+		actions = append(actions, feishuButton("Runbook", sum.RunbookURL, "default"))
+	}
+	if len(actions) > 0 {
+		elements = append(elements, map[string]any{"tag": "action", "actions": actions})
+	}
+
+	return map[string]any{
+		// update_multi 允许更新群聊中所有人看到的卡片
+		"config": map[string]any{"wide_screen_mode": true, "update_multi": true},
+		"header": map[string]any{
+			"title":    map[string]any{"tag": "plain_text", "content": statusIcon(ev.Status) + " " + title},
+			"template": feishuTemplate(ev.Severity, ev.Status),
+		},
+		"elements": elements,
+	}
+}
+
+// feishuFields 将字段渲染为两列布局，查询语句等较长的值独占一行
+func feishuFields(fields []Field) map[string]any {
+	out := make([]map[string]any, 0, len(fields))
+	for _, f := range fields {
+		out = append(out, map[string]any{
+			"is_short": len([]rune(f.Value)) <= 40,
+			"text":     map[string]any{"tag": "lark_md", "content": fmt.Sprintf("**%s**\n%s", f.Name, f.Value)},
+		})
+	}
+	return map[string]any{"tag": "div", "fields": out}
+}
+
+func feishuButton(text, link, kind string) map[string]any {
+	return map[string]any{
+		"tag":  "button",
+		"text": map[string]any{"tag": "plain_text", "content": text},
+		"type": kind,
+		"url":  link,
+	}
+}
+
+// feishuTemplate 返回卡片标题颜色：恢复为绿色，确认为蓝色，其余按告警级别着色
+func feishuTemplate(severity, status string) string {
+	switch status {
+	case StatusResolved:
+		return "green"
+	case StatusAcked:
+		return "blue"
+	}
+	switch strings.ToLower(severity) {
+	case "critical":
+		return "red"
+	case "high":
+		return "orange"
+	case "low":
+		return "wathet"
+	case "info":
+		return "grey"
+	default:
+		return "yellow"
+	}
+}
+
+func (f *FeishuNotifier) sendWebhook(ctx context.Context, card map[string]any) error {
 	payload := map[string]any{
 		"msg_type": "interactive",
-		"card": map[string]any{
-			"header": map[string]any{
-				"title": map[string]any{
-					"tag":     "plain_text",
-					"content": displayTitle,
-				},
-				// 统一使用红色模板，高优先级视觉效果更好
-				"template": "red",
-			},
-			"elements": []map[string]any{
-				{
-					"tag": "div",
-					"text": map[string]any{
-						"tag":     "lark_md",
-						"content": text,
-					},
-				},
-			},
-		},
+		"card":     card,
+	}
+	if f.Secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		payload["timestamp"] = ts
+		payload["sign"] = feishuSign(ts, f.Secret)
 	}
-	b, _ := json.Marshal(payload)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.Webhook, bytes.NewReader(b))
+	return f.do(ctx, http.MethodPost, f.Webhook, "", payload, nil)
+}
+
+// feishuSign 飞书自定义机器人签名：以 timestamp + "\n" + secret 为密钥对空串做 HmacSHA256 后 Base64
+func feishuSign(timestamp, secret string) string {
+	h := hmac.New(sha256.New, []byte(timestamp+"\n"+secret))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func (f *FeishuNotifier) sendApp(ctx context.Context, ev *AlertEvent, card map[string]any) error {
+	content, _ := json.Marshal(card)
+	if ev.Status == StatusResolved || ev.Status == StatusAcked {
+		// 恢复 / 确认时原地更新之前发送的卡片；找不到原消息（如已过期）时不再另发
+		msgs := f.sentMessages(ev.Fingerprint)
+		var errs []error
+		for _, id := range msgs {
+			path := "/open-apis/im/v1/messages/" + url.PathEscape(id)
+			if err := f.callAPI(ctx, http.MethodPatch, path, map[string]any{"content": string(content)}, nil); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if ev.Status == StatusResolved && len(errs) == 0 {
+			f.forgetMessages(ev.Fingerprint)
+		}
+		return errors.Join(errs...)
+	}
+
+	if len(f.Receivers) == 0 {
+		return Permanent(fmt.Errorf("feishu app: no receivers"))
+	}
+	var ids []string
+	var errs []error
+	for _, r := range f.Receivers {
+		var res struct {
+			Data struct {
+				MessageID string `json:"message_id"`
+			} `json:"data"`
+		}
+		path := "/open-apis/im/v1/messages?receive_id_type=" + url.QueryEscape(r.Type)
+		err := f.callAPI(ctx, http.MethodPost, path, map[string]any{
+			"receive_id": r.ID,
+			"msg_type":   "interactive",
+			"content":    string(content),
+		}, &res)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", r.Type, r.ID, err))
+			continue
+		}
+		ids = append(ids, res.Data.MessageID)
+	}
+	if len(ids) > 0 && ev.Fingerprint != "" {
+		f.saveMessages(ev.Fingerprint, ids)
+	}
+	// 部分接收方发送成功时不再重试，避免重复发送
+	if len(ids) > 0 {
+		for _, err := range errs {
+			logging.Errorf("飞书应用消息发送失败: %v", err)
+		}
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// callAPI 调用飞书开放平台接口，tenant_access_token 失效时刷新后重试一次
+func (f *FeishuNotifier) callAPI(ctx context.Context, method, path string, body any, out any) error {
+	for attempt := 0; ; attempt++ {
+		token, err := f.token.Get(ctx)
+		if err != nil {
+			return err
+		}
+		err = f.do(ctx, method, f.apiURL()+path, token, body, out)
+		var fe *feishuError
+		if attempt == 0 && errors.As(err, &fe) && fe.tokenInvalid() {
+			f.token.Invalidate()
+			continue
+		}
+		return err
+	}
+}
+
+func (f *FeishuNotifier) apiURL() string {
+	if f.APIURL != "" {
+		return strings.TrimRight(f.APIURL, "/")
+	}
+	return feishuAPIURL
+}
+
+// fetchToken 获取自建应用的 tenant_access_token
+func (f *FeishuNotifier) fetchToken(ctx context.Context) (string, time.Duration, error) {
+	var res struct {
+		Token  string `json:"tenant_access_token"`
+		Expire int    `json:"expire"`
+	}
+	err := f.do(ctx, http.MethodPost, f.apiURL()+"/open-apis/auth/v3/tenant_access_token/internal", "",
+		map[string]string{"app_id": f.AppID, "app_secret": f.AppSecret}, &res)
+	if err != nil {
+		return "", 0, fmt.Errorf("feishu tenant_access_token: %w", err)
+	}
+	return res.Token, time.Duration(res.Expire) * time.Second, nil
+}
+
+// feishuError 飞书在 HTTP 200 的响应体中通过 code 返回的错误
+type feishuError struct {
+	Code int
+	Msg  string
+}
+
+func (e *feishuError) Error() string { return fmt.Sprintf("feishu code=%d msg=%s", e.Code, e.Msg) }
+
+func (e *feishuError) tokenInvalid() bool {
+	for _, c := range feishuErrTokenInvalid {
+		if e.Code == c {
+			return true
+		}
+	}
+	return false
+}
+
+// do 发送请求并检查响应中的 code（群机器人旧版接口为 StatusCode），code 非 0 视为失败
+func (f *FeishuNotifier) do(ctx context.Context, method, endpoint, token string, body any, out any) error {
+	b, _ := json.Marshal(body)
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(b))
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
 	client := &http.Client{Timeout: f.Timeout}
 	resp, err := client.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+
+	var res struct {
+		Code       *int   `json:"code"`
+		Msg        string `json:"msg"`
+		StatusCode *int   `json:"StatusCode"`
+	}
+	if err := json.Unmarshal(data, &res); err != nil || (res.Code == nil && res.StatusCode == nil) {
+		if resp.StatusCode >= 300 {
+			return statusError("feishu", resp, data)
+		}
+		return nil
+	}
+	code := res.Code
+	if code == nil {
+		code = res.StatusCode
+	}
+	if *code != 0 {
+		fe := &feishuError{Code: *code, Msg: res.Msg}
+		switch {
+		case *code == feishuErrWebhookRateLimited || *code == feishuErrAPIRateLimited || resp.StatusCode == http.StatusTooManyRequests:
+			return Temporary(fe, retryAfter(resp.Header.Get("Retry-After")))
+		case fe.tokenInvalid() || resp.StatusCode >= 500:
+			return Temporary(fe, 0)
+		default:
+			return Permanent(fe)
+		}
+	}
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("feishu webhook status=%d", resp.StatusCode)
+		return statusError("feishu", resp, data)
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("feishu: decode response: %w", err)
+		}
 	}
 	return nil
 }
+
+func (f *FeishuNotifier) sentMessages(fingerprint string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.loadMessages()
+	m, ok := f.messages[fingerprint]
+	if !ok || time.Since(m.PostAt) > feishuMessageTTL {
+		return nil
+	}
+	return m.IDs
+}
+
+func (f *FeishuNotifier) saveMessages(fingerprint string, ids []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.loadMessages()
+	f.messages[fingerprint] = feishuMessages{IDs: ids, PostAt: time.Now()}
+	for k, m := range f.messages {
+		if time.Since(m.PostAt) > feishuMessageTTL {
+			delete(f.messages, k)
+		}
+	}
+	f.persistMessages()
+}
+
+func (f *FeishuNotifier) forgetMessages(fingerprint string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.loadMessages()
+	delete(f.messages, fingerprint)
+	f.persistMessages()
+}
+
+// loadMessages 首次使用时从状态文件恢复已发送的消息，调用方需持有 f.mu
+func (f *FeishuNotifier) loadMessages() {
+	if f.messages != nil {
+		return
+	}
+	f.messages = make(map[string]feishuMessages)
+	if f.MessageStateFile == "" {
+		return
+	}
+	data, err := os.ReadFile(f.MessageStateFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logging.Errorf("读取飞书消息状态失败: %v", err)
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &f.messages); err != nil {
+		logging.Errorf("解析飞书消息状态失败: %v", err)
+	}
+}
+
+// persistMessages 调用方需持有 f.mu
+func (f *FeishuNotifier) persistMessages() {
+	if f.MessageStateFile == "" {
+		return
+	}
+	data, _ := json.Marshal(f.messages)
+	if err := writeFileAtomic(f.MessageStateFile, data); err != nil {
+		logging.Errorf("保存飞书消息状态失败: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// FileNotifier 将告警记录按行追加写入本地 JSON Lines 文件，供离线环境留档。
+// 文件超过 MaxSize 时轮转：<path> → <path>.1 → … → <path>.<MaxBackups>，最旧的文件被删除。
+type FileNotifier struct {
+	Path       string
+	MaxSize    int64
+	MaxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func (f *FileNotifier) Name() string { return "file" }
+
+func (f *FileNotifier) WantsResolved() bool { return true }
+
+func (f *FileNotifier) WantsAcked() bool { return true }
+
+func (f *FileNotifier) Send(ctx context.Context, ev *AlertEvent) error {
+	line, err := json.Marshal(newAlertRecord(ev))
+	if err != nil {
+		return Permanent(err)
+	}
+	line = append(line, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return err
+		}
+	}
+	if f.size > 0 && f.size+int64(len(line)) > f.MaxSize {
+		if err := f.rotate(); err != nil {
+			return fmt.Errorf("file %s: rotate: %w", f.Path, err)
+		}
+	}
+	n, err := f.file.Write(line)
+	f.size += int64(n)
+	if err != nil {
+		// 下次发送时重新打开文件
+		f.file.Close()
+		f.file = nil
+		return fmt.Errorf("file %s: %w", f.Path, err)
+	}
+	return nil
+}
+
+func (f *FileNotifier) open() error {
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *FileNotifier) rotate() error {
+	f.file.Close()
+	f.file = nil
+	if f.MaxBackups <= 0 {
+		if err := os.Remove(f.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return f.open()
+	}
+	os.Remove(fmt.Sprintf("%s.%d", f.Path, f.MaxBackups))
+	for i := f.MaxBackups - 1; i >= 1; i-- {
+		src := fmt.Sprintf("%s.%d", f.Path, i)
+		if err := os.Rename(src, fmt.Sprintf("%s.%d", f.Path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(f.Path, f.Path+".1"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return f.open()
+}
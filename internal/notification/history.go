@@ -0,0 +1,183 @@
+package notification
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"elasticsearch-alert/internal/logging"
+)
+
+// 发送历史中的结果
+const (
+	HistorySent     = "sent"
+	HistoryRetrying = "retrying"
+	HistoryFailed   = "failed"
+)
+
+// historyOutputLimit 每条历史记录保存的动作输出上限（字节），完整输出请在命令自身的日志中查看
+const historyOutputLimit = 4 * 1024
+
+// HistoryEntry 是告警历史中的一条记录：某条告警在某个渠道上的一次发送（或命令执行）结果
+type HistoryEntry struct {
+	Time        time.Time `json:"time"`
+	Rule        string    `json:"rule"`
+	Channel     string    `json:"channel"`
+	Status      string    `json:"status,omitempty"` // firing / resolved / acked
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	Title       string    `json:"title,omitempty"`
+	Count       int       `json:"count"`
+	Result      string    `json:"result"`
+	Attempt     int       `json:"attempt"`
+	Error       string    `json:"error,omitempty"`
+	// Output 动作类渠道（如 exec）的输出
+	Output string `json:"output,omitempty"`
+	// WindowStart / WindowEnd 触发本次通知的评估时间窗
+	WindowStart *time.Time `json:"windowStart,omitempty"`
+	WindowEnd   *time.Time `json:"windowEnd,omitempty"`
+}
+
+// History 保存最近的发送历史，配置了 spoolDir 时以 NDJSON 追加写入 <spoolDir>/history.ndjson，
+// 文件行数超过容量的两倍时重写为内存中的最近记录。nil History 表示不记录。
+type History struct {
+	mu      sync.Mutex
+	size    int
+	file    string
+	lines   int // 文件中的行数
+	entries []HistoryEntry
+}
+
+func newHistory(size int, dir string) *History {
+	if size <= 0 {
+		size = 1000
+	}
+	h := &History{size: size}
+	if dir == "" {
+		return h
+	}
+	h.file = filepath.Join(dir, "history.ndjson")
+	f, err := os.Open(h.file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logging.Errorf("读取发送历史失败: %v", err)
+		}
+		return h
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		h.lines++
+		var e HistoryEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			// 进程退出时可能留下写了一半的最后一行
+			logging.Errorf("发送历史第 %d 行已损坏，跳过: %v", h.lines, err)
+			continue
+		}
+		h.entries = append(h.entries, e)
+		if len(h.entries) > h.size {
+			h.entries = h.entries[1:]
+		}
+	}
+	if err := sc.Err(); err != nil {
+		logging.Errorf("读取发送历史失败: %v", err)
+	}
+	return h
+}
+
+// Add 追加一条历史记录，超出容量时丢弃最旧的记录
+func (h *History) Add(e HistoryEntry) {
+	if h == nil {
+		return
+	}
+	e.Output = truncateBytes(e.Output, historyOutputLimit)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = append(h.entries, e)
+	if len(h.entries) > h.size {
+		h.entries = h.entries[len(h.entries)-h.size:]
+	}
+	if h.file == "" {
+		return
+	}
+	if h.lines >= 2*h.size {
+		h.compact()
+		return
+	}
+	line, _ := json.Marshal(e)
+	if err := appendLine(h.file, line); err != nil {
+		logging.Errorf("发送历史落盘失败: %v", err)
+		return
+	}
+	h.lines++
+}
+
+// compact 将文件重写为内存中的最近记录，调用方需持有 h.mu
+func (h *History) compact() {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range h.entries {
+		_ = enc.Encode(e)
+	}
+	if err := writeFileAtomic(h.file, buf.Bytes()); err != nil {
+		logging.Errorf("压缩发送历史失败: %v", err)
+		return
+	}
+	h.lines = len(h.entries)
+}
+
+// appendLine 向文件末尾追加一行
+func appendLine(path string, line []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// List 返回最近的历史记录（最新的在前），rule 非空时只返回该规则的记录，limit <= 0 表示不限制条数
+func (h *History) List(rule string, limit int) []HistoryEntry {
+	out := []HistoryEntry{}
+	if h == nil {
+		return out
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		if rule != "" && h.entries[i].Rule != rule {
+			continue
+		}
+		out = append(out, h.entries[i])
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+	}
+	return out
+}
+
+// actionOutput 由分发器放入发送 context，动作类渠道把输出写入其中，分发器记录到发送历史
+type actionOutput struct {
+	text string
+}
+
+type actionOutputKey struct{}
+
+func withActionOutput(ctx context.Context, out *actionOutput) context.Context {
+	return context.WithValue(ctx, actionOutputKey{}, out)
+}
+
+// recordOutput 记录动作类渠道本次发送的输出
+func recordOutput(ctx context.Context, text string) {
+	if out, ok := ctx.Value(actionOutputKey{}).(*actionOutput); ok {
+		out.text = text
+	}
+}
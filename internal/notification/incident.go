@@ -0,0 +1,82 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+)
+
+// Incident 是事件类平台（PagerDuty / Opsgenie 等）上的一个告警事件。
+// Key 在同一告警的打开与关闭之间保持不变，用作平台侧的去重键。
+type Incident struct {
+	Key   string
+	Event *AlertEvent
+}
+
+// IncidentBackend 由事件类平台实现，只需要理解打开 / 确认 / 关闭三种操作，
+// 重复打开同一个 Key 时由平台负责合并。
+type IncidentBackend interface {
+	Name() string
+	Open(ctx context.Context, inc Incident) error
+	Ack(ctx context.Context, inc Incident) error
+	Close(ctx context.Context, inc Incident) error
+}
+
+// IncidentNotifier 将 IncidentBackend 适配为 Notifier：firing 打开事件，acked 确认事件，resolved 关闭事件。
+// 事件类平台总是需要恢复通知，否则事件会一直保持打开。
+type IncidentNotifier struct {
+	Backend IncidentBackend
+}
+
+func (n *IncidentNotifier) Name() string { return n.Backend.Name() }
+
+func (n *IncidentNotifier) WantsResolved() bool { return true }
+
+func (n *IncidentNotifier) WantsAcked() bool { return true }
+
+func (n *IncidentNotifier) TakesAction() bool { return true }
+
+func (n *IncidentNotifier) Send(ctx context.Context, ev *AlertEvent) error {
+	if ev.Fingerprint == "" {
+		return Permanent(fmt.Errorf("%s: missing dedup key", n.Backend.Name()))
+	}
+	inc := Incident{Key: ev.Fingerprint, Event: ev}
+	switch ev.Status {
+	case StatusResolved:
+		return n.Backend.Close(ctx, inc)
+	case StatusAcked:
+		return n.Backend.Ack(ctx, inc)
+	}
+	return n.Backend.Open(ctx, inc)
+}
+
+// incidentDetails 汇总概览字段、描述、样例日志与标签，作为事件的附加详情
+func incidentDetails(d *AlertEvent) map[string]string {
+	details := make(map[string]string)
+	for _, f := range d.Summary.Overview {
+		details[f.Name] = f.Value
+	}
+	for _, f := range d.Summary.Target {
+		details[f.Name] = f.Value
+	}
+	if d.Summary.Description != "" {
+		details["description"] = d.Summary.Description
+	}
+	if d.Summary.Log != "" {
+		details["sample_log"] = d.Summary.Log
+	}
+	for k, v := range d.Labels {
+		details["label."+k] = v
+	}
+	return details
+}
+
+// incidentSource 返回事件来源：优先使用配置，其次是规则的索引
+func incidentSource(configured string, d *AlertEvent) string {
+	if configured != "" {
+		return configured
+	}
+	if d.Index != "" {
+		return d.Index
+	}
+	return "elasticsearch-alert"
+}
@@ -0,0 +1,266 @@
+package notification
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// emailMessage 一封告警邮件：multipart/alternative（纯文本 + HTML），有附件时外层为 multipart/mixed
+type emailMessage struct {
+	From        string
+	To          []string
+	Cc          []string
+	Subject     string
+	Text        string
+	HTML        string
+	Attachments []emailAttachment
+	Date        time.Time
+}
+
+type emailAttachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Bytes 按固定顺序输出邮件头（Subject 与地址中的显示名按 RFC 2047 编码）与 MIME 正文
+func (m *emailMessage) Bytes() []byte {
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	var buf bytes.Buffer
+	header := func(k, v string) {
+		if v != "" {
+			buf.WriteString(k + ": " + v + "\r\n")
+		}
+	}
+	header("Date", date.Format(time.RFC1123Z))
+	header("Message-ID", newEmailMessageID(m.From))
+	header("From", formatAddressList([]string{m.From}))
+	header("To", formatAddressList(m.To))
+	header("Cc", formatAddressList(m.Cc))
+	header("Subject", mime.BEncoding.Encode("UTF-8", m.Subject))
+	header("MIME-Version", "1.0")
+
+	alt := func(w *multipart.Writer) {
+		writeTextPart(w, "text/plain; charset=UTF-8", m.Text)
+		writeTextPart(w, "text/html; charset=UTF-8", m.HTML)
+		w.Close()
+	}
+
+	if len(m.Attachments) == 0 {
+		w := multipart.NewWriter(&buf)
+		header("Content-Type", `multipart/alternative; boundary="`+w.Boundary()+`"`)
+		buf.WriteString("\r\n")
+		alt(w)
+		return buf.Bytes()
+	}
+
+	mixed := multipart.NewWriter(&buf)
+	header("Content-Type", `multipart/mixed; boundary="`+mixed.Boundary()+`"`)
+	buf.WriteString("\r\n")
+	var altBuf bytes.Buffer
+	altW := multipart.NewWriter(&altBuf)
+	alt(altW)
+	part, _ := mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {`multipart/alternative; boundary="` + altW.Boundary() + `"`},
+	})
+	part.Write(altBuf.Bytes())
+	for _, a := range m.Attachments {
+		part, _ := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(a.ContentType, map[string]string{"name": a.Filename})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		writeBase64Lines(part, a.Data)
+	}
+	mixed.Close()
+	return buf.Bytes()
+}
+
+func writeTextPart(w *multipart.Writer, contentType, body string) {
+	part, _ := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	qp := quotedprintable.NewWriter(part)
+	qp.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n")))
+	qp.Close()
+}
+
+// writeBase64Lines 按 RFC 2045 每行 76 个字符输出 base64
+func writeBase64Lines(w interface{ Write([]byte) (int, error) }, data []byte) {
+	enc := base64.StdEncoding.EncodeToString(data)
+	for len(enc) > 76 {
+		w.Write([]byte(enc[:76] + "\r\n"))
+		enc = enc[76:]
+	}
+	w.Write([]byte(enc + "\r\n"))
+}
+
+// formatAddressList 解析 "张三 <a@example.com>" 形式的地址，显示名按 RFC 2047 编码；无法解析时原样输出
+func formatAddressList(addrs []string) string {
+	out := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		if a == "" {
+			continue
+		}
+		if p, err := mail.ParseAddress(a); err == nil {
+			out = append(out, p.String())
+		} else {
+			out = append(out, a)
+		}
+	}
+	return strings.Join(out, ", ")
+}
+
+// envelopeAddress 返回 SMTP 信封（MAIL FROM / RCPT TO）使用的裸地址
+func envelopeAddress(a string) string {
+	if p, err := mail.ParseAddress(a); err == nil {
+		return p.Address
+	}
+	return a
+}
+
+func newEmailMessageID(from string) string {
+	domain := "elasticsearch-alert"
+	if i := strings.LastIndex(envelopeAddress(from), "@"); i >= 0 {
+		domain = envelopeAddress(from)[i+1:]
+	}
+	var b [12]byte
+	rand.Read(b[:])
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(b[:]), domain)
+}
+
+// emailHTML 将告警正文渲染为简单的 HTML 卡片，支持少量 Markdown（**加粗**、换行）
+func emailHTML(subject string, ev *AlertEvent) string {
+	border, background := "#f5c6cb", "#fdecea"
+	heading := "Elasticsearch 日志告警"
+	switch ev.Status {
+	case StatusResolved:
+		border, background = "#c3e6cb", "#eaf6ec"
+		heading = "Elasticsearch 日志告警已恢复"
+	case StatusAcked:
+		border, background = "#ffeeba", "#fff8e1"
+		heading = "Elasticsearch 日志告警已确认"
+	}
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="UTF-8">
+  <title>%s</title>
+  <style>
+    body { font-family: -apple-system,BlinkMacSystemFont,Segoe UI,Roboto,Helvetica,Arial,sans-serif; margin: 20px; color: #333; }
+    .card { border-radius: 10px; border: 1px solid %s; background-color: %s; padding: 16px 20px; margin-bottom: 20px; }
+    .card h2 { margin: 0 0 8px 0; }
+    .content { background: #f8f9fa; border-radius: 6px; padding: 12px 16px; white-space: pre-wrap; font-family: Menlo,Consolas,monospace; }
+  </style>
+</head>
+<body>
+  <div class="card">
+    <h2>%s %s</h2>
+    <div>%s</div>
+  </div>
+  <div class="content">%s</div>
+</body>
+</html>
+`, html.EscapeString(subject), border, background, statusIcon(ev.Status), heading, html.EscapeString(subject), markdownToHTML(ev.Text))
+}
+
+var markdownBold = regexp.MustCompile(`\*\*(.*?)\*\*`)
+
+// emailText 生成纯文本正文：去掉 Markdown 加粗标记
+func emailText(ev *AlertEvent) string {
+	return markdownBold.ReplaceAllString(ev.Text, "$1")
+}
+
+// sampleAttachment 将样例文档导出为 CSV 或 NDJSON 附件，嵌套字段展开为 a.b.c 列
+func sampleAttachment(format string, ev *AlertEvent) (emailAttachment, bool) {
+	if len(ev.Samples) == 0 {
+		return emailAttachment{}, false
+	}
+	name := attachmentName.ReplaceAllString(ev.Rule, "_")
+	if name == "" {
+		name = "alert"
+	}
+	switch strings.ToLower(format) {
+	case "ndjson":
+		var buf bytes.Buffer
+		for _, doc := range ev.Samples {
+			b, _ := json.Marshal(doc)
+			buf.Write(b)
+			buf.WriteByte('\n')
+		}
+		return emailAttachment{Filename: name + "-samples.ndjson", ContentType: "application/x-ndjson", Data: buf.Bytes()}, true
+	case "csv":
+		rows := make([]map[string]string, 0, len(ev.Samples))
+		columns := map[string]bool{}
+		for _, doc := range ev.Samples {
+			row := map[string]string{}
+			flattenDoc("", doc, row)
+			for k := range row {
+				columns[k] = true
+			}
+			rows = append(rows, row)
+		}
+		keys := make([]string, 0, len(columns))
+		for k := range columns {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var buf bytes.Buffer
+		// 带 BOM，Excel 打开时能正确识别 UTF-8
+		buf.WriteString("\ufeff")
+		w := csv.NewWriter(&buf)
+		w.Write(keys)
+		for _, row := range rows {
+			rec := make([]string, len(keys))
+			for i, k := range keys {
+				rec[i] = row[k]
+			}
+			w.Write(rec)
+		}
+		w.Flush()
+		return emailAttachment{Filename: name + "-samples.csv", ContentType: "text/csv", Data: buf.Bytes()}, true
+	}
+	return emailAttachment{}, false
+}
+
+var attachmentName = regexp.MustCompile(`[^\p{L}\p{N}._-]+`)
+
+func flattenDoc(prefix string, v any, out map[string]string) {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, sub := range t {
+			key := k
+			if prefix != "" {
+				key = prefix + "." + k
+			}
+			flattenDoc(key, sub, out)
+		}
+	case nil:
+		out[prefix] = ""
+	case string:
+		out[prefix] = t
+	default:
+		b, _ := json.Marshal(t)
+		out[prefix] = string(b)
+	}
+}
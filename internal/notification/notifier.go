@@ -1,114 +1,233 @@
 package notification
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
-	"net/http"
 	"time"
 
 	"elasticsearch-alert/internal/config"
 )
 
+// Notifier 是一个通知渠道，接收结构化的告警事件
 type Notifier interface {
 	Name() string
-	Send(ctx context.Context, title, text string) error
+	Send(ctx context.Context, ev *AlertEvent) error
 }
 
-func BuildNotifiers(cfg config.Notifications) []Notifier {
+// TextNotifier 是只需要标题与 Markdown 正文的简单渠道，通过 Text 适配为 Notifier
+type TextNotifier interface {
+	Name() string
+	SendText(ctx context.Context, title, text string) error
+}
+
+// Text 将 TextNotifier 适配为 Notifier，发送事件的 Title 与 Text
+func Text(n TextNotifier) Notifier { return textAdapter{n} }
+
+type textAdapter struct{ TextNotifier }
+
+func (a textAdapter) Send(ctx context.Context, ev *AlertEvent) error {
+	return a.SendText(ctx, ev.Title, ev.Text)
+}
+
+// ResolveNotifier 由需要完整告警生命周期的渠道实现（如 PagerDuty / Opsgenie 等事件类平台）：
+// WantsResolved 返回 true 时，告警恢复后总会收到 Status=resolved 的通知，不受规则 sendResolved 影响。
+type ResolveNotifier interface {
+	WantsResolved() bool
+}
+
+// AckNotifier 由需要接收告警确认事件的渠道实现（如 CloudEvents 输出）：
+// WantsAcked 返回 true 时，告警被确认后会收到 Status=acked 的通知。
+type AckNotifier interface {
+	WantsAcked() bool
+}
+
+// RepeatNotifier 由要求持续推送的渠道实现（如 Alertmanager）：
+// WantsRepeat 返回 true 时，告警持续期间每次规则评估都会通知该渠道，不受静默期影响。
+type RepeatNotifier interface {
+	WantsRepeat() bool
+}
+
+// FilterNotifier 由只接收部分告警的渠道实现（如只发送高级别告警的钉钉工作通知）：
+// Accepts 返回 false 的事件不会进入该渠道的发送队列，也不会记入发送历史。
+type FilterNotifier interface {
+	Accepts(ev *AlertEvent) bool
+}
+
+// ActionNotifier 由会触发动作的渠道实现（如执行修复命令的 exec、创建事件并呼叫值班人员的 PagerDuty / Opsgenie）：
+// TakesAction 返回 true 时，已经过去的补查告警默认不发送到该渠道，需要在规则的 catchup.channels 中显式开启。
+type ActionNotifier interface {
+	TakesAction() bool
+}
+
+// CostNotifier 由一次发送需要调用多次平台接口的渠道实现（如群机器人正文之后再发一条 @ 提醒）：
+// Cost 返回发送该事件消耗的限流令牌数，未实现时为 1。
+type CostNotifier interface {
+	Cost(ev *AlertEvent) int
+}
+
+func BuildNotifiers(cfg config.Notifications) ([]Notifier, error) {
 	var notifiers []Notifier
-	notifiers = append(notifiers, &ConsoleNotifier{})
+	notifiers = append(notifiers, Text(&ConsoleNotifier{}))
 	if cfg.Webhook.URL != "" {
-		notifiers = append(notifiers, &WebhookNotifier{
-			URL:     cfg.Webhook.URL,
-			Headers: cfg.Webhook.Headers,
-			Timeout: parseDurationDefault(cfg.Webhook.Timeout, 5*time.Second),
+		w, err := NewWebhookNotifier(cfg.Webhook)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, w)
+	}
+	if cfg.Feishu.Webhook != "" || cfg.Feishu.App.AppID != "" {
+		notifiers = append(notifiers, NewFeishuNotifier(cfg.Feishu))
+	}
+	if cfg.PagerDuty.RoutingKey != "" {
+		notifiers = append(notifiers, &IncidentNotifier{Backend: &PagerDutyBackend{
+			RoutingKey: cfg.PagerDuty.RoutingKey,
+			EventsURL:  cfg.PagerDuty.EventsURL,
+			Source:     cfg.PagerDuty.Source,
+			ClientURL:  cfg.PagerDuty.ClientURL,
+			Timeout:    parseDurationDefault(cfg.PagerDuty.Timeout, 10*time.Second),
+		}})
+	}
+	if cfg.Opsgenie.APIKey != "" {
+		notifiers = append(notifiers, &IncidentNotifier{Backend: &OpsgenieBackend{
+			APIKey:     cfg.Opsgenie.APIKey,
+			APIURL:     cfg.Opsgenie.APIURL,
+			Responders: cfg.Opsgenie.Responders,
+			Tags:       cfg.Opsgenie.Tags,
+			Source:     cfg.Opsgenie.Source,
+			Timeout:    parseDurationDefault(cfg.Opsgenie.Timeout, 10*time.Second),
+		}})
+	}
+	if len(cfg.Alertmanager.URLs) > 0 {
+		notifiers = append(notifiers, &AlertmanagerNotifier{
+			URLs:           cfg.Alertmanager.URLs,
+			Username:       cfg.Alertmanager.Username,
+			Password:       cfg.Alertmanager.Password,
+			BearerToken:    cfg.Alertmanager.BearerToken,
+			ResolveTimeout: parseDurationDefault(cfg.Alertmanager.ResolveTimeout, 5*time.Minute),
+			Timeout:        parseDurationDefault(cfg.Alertmanager.Timeout, 10*time.Second),
 		})
 	}
-	if cfg.Feishu.Webhook != "" {
-		notifiers = append(notifiers, &FeishuNotifier{
-			Webhook:      cfg.Feishu.Webhook,
-			EnableAtAll:  cfg.Feishu.EnableAtAll,
-			Timeout:      parseDurationDefault(cfg.Feishu.Timeout, 5*time.Second),
-			TitlePrefix:  cfg.Feishu.TitlePrefix,
-			ContentIntro: cfg.Feishu.ContentIntro,
+	if cfg.CloudEvents.Kafka.RestProxyURL != "" {
+		if cfg.CloudEvents.Kafka.Topic == "" {
+			return nil, fmt.Errorf("cloudevents.kafka: topic required")
+		}
+		notifiers = append(notifiers, &KafkaNotifier{
+			Format:       newCloudEventFormat(cfg.CloudEvents.Source, cfg.CloudEvents.TypePrefix),
+			RestProxyURL: cfg.CloudEvents.Kafka.RestProxyURL,
+			Topic:        cfg.CloudEvents.Kafka.Topic,
+			Username:     cfg.CloudEvents.Kafka.Username,
+			Password:     cfg.CloudEvents.Kafka.Password,
+			Timeout:      parseDurationDefault(cfg.CloudEvents.Kafka.Timeout, 10*time.Second),
 		})
 	}
-	if cfg.DingTalk.Webhook != "" {
-		notifiers = append(notifiers, &DingTalkNotifier{
-			Webhook:     cfg.DingTalk.Webhook,
-			Secret:      cfg.DingTalk.Secret,
-			EnableAtAll: cfg.DingTalk.EnableAtAll,
-			Timeout:     parseDurationDefault(cfg.DingTalk.Timeout, 5*time.Second),
+	if cfg.CloudEvents.File.Path != "" {
+		notifiers = append(notifiers, &EventFileNotifier{
+			Format: newCloudEventFormat(cfg.CloudEvents.Source, cfg.CloudEvents.TypePrefix),
+			Path:   cfg.CloudEvents.File.Path,
+		})
+	}
+	if cfg.Syslog.Address != "" || cfg.Syslog.Network != "" {
+		s, err := NewSyslogNotifier(cfg.Syslog)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, s)
+	}
+	if cfg.File.Path != "" {
+		maxSize := cfg.File.MaxSizeMB
+		if maxSize <= 0 {
+			maxSize = 100
+		}
+		maxBackups := cfg.File.MaxBackups
+		if maxBackups <= 0 {
+			maxBackups = 5
+		}
+		notifiers = append(notifiers, &FileNotifier{
+			Path:       cfg.File.Path,
+			MaxSize:    int64(maxSize) << 20,
+			MaxBackups: maxBackups,
 		})
 	}
-	if cfg.WeChat.Webhook != "" {
-		notifiers = append(notifiers, &WeChatNotifier{
-			Webhook: cfg.WeChat.Webhook,
-			Timeout: parseDurationDefault(cfg.WeChat.Timeout, 5*time.Second),
+	execs, err := NewExecNotifiers(cfg.Exec)
+	if err != nil {
+		return nil, err
+	}
+	notifiers = append(notifiers, execs...)
+	if cfg.Teams.Webhook != "" {
+		notifiers = append(notifiers, &TeamsNotifier{
+			Webhook:     cfg.Teams.Webhook,
+			Timeout:     parseDurationDefault(cfg.Teams.Timeout, 5*time.Second),
+			TitlePrefix: cfg.Teams.TitlePrefix,
+			MaxBytes:    cfg.Teams.MaxBytes,
 		})
 	}
-	if cfg.Email.Host != "" && cfg.Email.From != "" && len(cfg.Email.To) > 0 {
-		notifiers = append(notifiers, &EmailNotifier{
-			Host:          cfg.Email.Host,
-			Port:          cfg.Email.Port,
-			Username:      cfg.Email.Username,
-			Password:      cfg.Email.Password,
-			From:          cfg.Email.From,
-			To:            cfg.Email.To,
-			UseTLS:        cfg.Email.UseTLS,
-			TLSSkipVerify: cfg.Email.TLSSkipVerify,
-			SubjectPrefix: cfg.Email.SubjectPrefix,
-			Timeout:       parseDurationDefault(cfg.Email.Timeout, 10*time.Second),
+	if cfg.DingTalk.Webhook != "" {
+		d, err := NewDingTalkNotifier(cfg.DingTalk)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, d)
+	}
+	if cfg.DingTalk.App.AppKey != "" {
+		d, err := NewDingTalkWorkNotifier(cfg.DingTalk)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, d)
+	}
+	if cfg.WeChat.Webhook != "" || cfg.WeChat.App.CorpID != "" {
+		w, err := NewWeChatNotifier(cfg.WeChat)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, w)
+	}
+	if cfg.Slack.Webhook != "" || cfg.Slack.Token != "" {
+		notifiers = append(notifiers, &SlackNotifier{
+			Webhook:         cfg.Slack.Webhook,
+			Token:           cfg.Slack.Token,
+			Channel:         cfg.Slack.Channel,
+			APIURL:          cfg.Slack.APIURL,
+			Timeout:         parseDurationDefault(cfg.Slack.Timeout, 5*time.Second),
+			ThreadTTL:       parseDurationDefault(cfg.Slack.ThreadTTL, 24*time.Hour),
+			ThreadStateFile: cfg.Slack.ThreadStateFile,
 		})
 	}
-	return notifiers
+	if cfg.Telegram.BotToken != "" && len(cfg.Telegram.Chats) > 0 {
+		tg, err := NewTelegramNotifier(cfg.Telegram)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, tg)
+	}
+	// 收件人可以为空：此时邮件渠道只用于通知值班人员
+	if cfg.Email.Host != "" && cfg.Email.From != "" {
+		em, err := NewEmailNotifier(cfg.Email)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, em)
+	}
+	return notifiers, nil
 }
 
 // Console
 type ConsoleNotifier struct{}
 
 func (c *ConsoleNotifier) Name() string { return "console" }
-func (c *ConsoleNotifier) Send(ctx context.Context, title, text string) error {
+func (c *ConsoleNotifier) SendText(ctx context.Context, title, text string) error {
 	log.Printf("[ALERT][console] %s\n%s", title, text)
 	return nil
 }
 
-// Webhook
-type WebhookNotifier struct {
-	URL     string
-	Headers map[string]string
-	Timeout time.Duration
-}
-
-func (w *WebhookNotifier) Name() string { return "webhook" }
-func (w *WebhookNotifier) Send(ctx context.Context, title, text string) error {
-	body := map[string]any{
-		"title":   title,
-		"message": text,
-		"ts":      time.Now().Format(time.RFC3339),
-	}
-	data, _ := json.Marshal(body)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(data))
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	for k, v := range w.Headers {
-		req.Header.Set(k, v)
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
 	}
-	client := &http.Client{Timeout: w.Timeout}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= 300 {
-		return fmt.Errorf("webhook status=%d", resp.StatusCode)
-	}
-	return nil
+	return false
 }
 
 func parseDurationDefault(s string, def time.Duration) time.Duration {
@@ -121,5 +240,3 @@ func parseDurationDefault(s string, def time.Duration) time.Duration {
 	}
 	return d
 }
-
-
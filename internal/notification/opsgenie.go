@@ -0,0 +1,173 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"elasticsearch-alert/internal/config"
+)
+
+const opsgenieAPIURL = "https://api.opsgenie.com"
+
+// OpsgenieBackend 通过 Alert API 创建 / 确认 / 关闭 Opsgenie 告警，alias 使用告警指纹用于去重。
+// 值班人员（带邮箱的收件人）会作为 user 类型的 responder 追加到配置的 responders 中。
+type OpsgenieBackend struct {
+	APIKey     string
+	APIURL     string
+	Responders []config.OpsgenieResponder
+	Tags       []string
+	Source     string
+	Timeout    time.Duration
+}
+
+func (o *OpsgenieBackend) Name() string { return "opsgenie" }
+
+func (o *OpsgenieBackend) Open(ctx context.Context, inc Incident) error {
+	d := inc.Event
+	desc := d.Summary.Description
+	if d.Summary.Log != "" {
+		desc = strings.TrimSpace(desc + "\n\n" + d.Summary.Log)
+	}
+	if d.Summary.DetailURL != "" {
+		desc += "\n\n查看日志详情: " + d.Summary.DetailURL
+	}
+	if d.Summary.DiscoverURL != "" {
+		desc += "\nDiscover: " + d.Summary.DiscoverURL
+	}
+	if d.Summary.RunbookURL != "" {
+		desc += "\nRunbook: " + d.Summary.RunbookURL
+	}
+
+	payload := map[string]any{
+		"message":     truncateRunes(d.Title, 130),
+		"alias":       inc.Key,
+		"description": truncateRunes(desc, 15000),
+		"priority":    opsgeniePriority(d.Severity),
+		"source":      incidentSource(o.Source, d),
+		"entity":      d.Rule,
+		"details":     incidentDetails(d),
+	}
+	if responders := o.responders(d); len(responders) > 0 {
+		payload["responders"] = responders
+	}
+	if tags := o.tags(d); len(tags) > 0 {
+		payload["tags"] = tags
+	}
+	return o.post(ctx, "/v2/alerts", payload)
+}
+
+func (o *OpsgenieBackend) Ack(ctx context.Context, inc Incident) error {
+	path := fmt.Sprintf("/v2/alerts/%s/acknowledge?identifierType=alias", url.PathEscape(inc.Key))
+	payload := map[string]any{
+		"source": incidentSource(o.Source, inc.Event),
+		"note":   "告警已确认",
+	}
+	if inc.Event.AckedBy != "" {
+		payload["note"] = "告警已由 " + inc.Event.AckedBy + " 确认"
+	}
+	return o.post(ctx, path, payload)
+}
+
+func (o *OpsgenieBackend) Close(ctx context.Context, inc Incident) error {
+	path := fmt.Sprintf("/v2/alerts/%s/close?identifierType=alias", url.PathEscape(inc.Key))
+	return o.post(ctx, path, map[string]any{
+		"source": incidentSource(o.Source, inc.Event),
+		"note":   "规则已恢复",
+	})
+}
+
+func (o *OpsgenieBackend) responders(d *AlertEvent) []map[string]string {
+	var out []map[string]string
+	seen := make(map[string]bool)
+	add := func(r map[string]string) {
+		key := r["type"] + "|" + r["id"] + "|" + r["name"] + "|" + r["username"]
+		if !seen[key] {
+			seen[key] = true
+			out = append(out, r)
+		}
+	}
+	for _, r := range o.Responders {
+		m := map[string]string{"type": r.Type}
+		switch {
+		case r.ID != "":
+			m["id"] = r.ID
+		case r.Username != "":
+			m["username"] = r.Username
+		default:
+			m["name"] = r.Name
+		}
+		add(m)
+	}
+	for _, r := range d.Recipients {
+		if r.Email != "" {
+			add(map[string]string{"type": "user", "username": r.Email})
+		}
+	}
+	return out
+}
+
+// tags 合并配置中的标签、规则级别与规则 labels（key:value 形式）
+func (o *OpsgenieBackend) tags(d *AlertEvent) []string {
+	tags := append([]string{}, o.Tags...)
+	if d.Severity != "" {
+		tags = append(tags, "severity:"+strings.ToLower(d.Severity))
+	}
+	keys := make([]string, 0, len(d.Labels))
+	for k := range d.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		tags = append(tags, k+":"+d.Labels[k])
+	}
+	return mergeUnique(tags)
+}
+
+func (o *OpsgenieBackend) post(ctx context.Context, path string, payload map[string]any) error {
+	apiURL := strings.TrimRight(o.APIURL, "/")
+	if apiURL == "" {
+		apiURL = opsgenieAPIURL
+	}
+	b, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL+path, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "GenieKey "+o.APIKey)
+	client := &http.Client{Timeout: o.Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return statusError("opsgenie", resp, body)
+	}
+	return nil
+}
+
+// opsgeniePriority 将规则级别映射为 Opsgenie 的 P1 ~ P5
+func opsgeniePriority(severity string) string {
+	switch strings.ToLower(severity) {
+	case "critical":
+		return "P1"
+	case "high":
+		return "P2"
+	case "low":
+		return "P4"
+	case "info":
+		return "P5"
+	default:
+		return "P3"
+	}
+}
@@ -0,0 +1,115 @@
+package notification
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"elasticsearch-alert/internal/config"
+)
+
+func TestOpsgenieLifecycle(t *testing.T) {
+	var auth []string
+	srv := newStubServer(t, func(w http.ResponseWriter, r *http.Request) int {
+		auth = append(auth, r.Header.Get("Authorization"))
+		return http.StatusAccepted
+	})
+	n := &IncidentNotifier{Backend: &OpsgenieBackend{
+		APIKey:     "key",
+		APIURL:     srv.URL,
+		Responders: []config.OpsgenieResponder{{Type: "team", Name: "sre"}},
+		Tags:       []string{"logs"},
+		Timeout:    time.Second,
+	}}
+
+	firing := testIncidentEvent(StatusFiring)
+	firing.Recipients = []Recipient{{Name: "张三", Email: "zhangsan@example.com"}}
+	acked := testIncidentEvent(StatusAcked)
+	acked.AckedBy = "张三"
+	for _, ev := range []*AlertEvent{firing, acked, testIncidentEvent(StatusResolved)} {
+		if err := n.Send(context.Background(), ev); err != nil {
+			t.Fatalf("send %s: %v", ev.Status, err)
+		}
+	}
+
+	wantPaths := []string{
+		"/v2/alerts",
+		"/v2/alerts/esalert-0123456789abcdef/acknowledge?identifierType=alias",
+		"/v2/alerts/esalert-0123456789abcdef/close?identifierType=alias",
+	}
+	if len(srv.paths) != len(wantPaths) {
+		t.Fatalf("paths = %v, want %v", srv.paths, wantPaths)
+	}
+	for i, want := range wantPaths {
+		if srv.paths[i] != want {
+			t.Errorf("request %d: path = %s, want %s", i, srv.paths[i], want)
+		}
+		if auth[i] != "GenieKey key" {
+			t.Errorf("request %d: Authorization = %q", i, auth[i])
+		}
+	}
+
+	create := srv.bodies[0]
+	if create["alias"] != "esalert-0123456789abcdef" {
+		t.Errorf("alias = %v, want the fingerprint", create["alias"])
+	}
+	if create["priority"] != "P2" {
+		t.Errorf("priority = %v, want P2", create["priority"])
+	}
+	responders := create["responders"].([]any)
+	if len(responders) != 2 {
+		t.Fatalf("responders = %v, want the configured team and the on-call user", responders)
+	}
+	if r := responders[1].(map[string]any); r["type"] != "user" || r["username"] != "zhangsan@example.com" {
+		t.Errorf("on-call responder = %v", r)
+	}
+	tags := create["tags"].([]any)
+	if len(tags) != 3 || tags[0] != "logs" || tags[1] != "severity:high" || tags[2] != "team:payment" {
+		t.Errorf("tags = %v", tags)
+	}
+	if note := srv.bodies[1]["note"]; note != "告警已由 张三 确认" {
+		t.Errorf("ack note = %v", note)
+	}
+}
+
+func TestOpsgenieErrorClassification(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		retryable bool
+	}{
+		{name: "rate limited", status: http.StatusTooManyRequests, retryable: true},
+		{name: "unavailable", status: http.StatusServiceUnavailable, retryable: true},
+		{name: "unauthorized", status: http.StatusUnauthorized},
+		{name: "unprocessable", status: http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newStubServer(t, func(w http.ResponseWriter, r *http.Request) int { return tt.status })
+			n := &IncidentNotifier{Backend: &OpsgenieBackend{APIKey: "key", APIURL: srv.URL, Timeout: time.Second}}
+			err := n.Send(context.Background(), testIncidentEvent(StatusResolved))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if retryable, _ := classify(err); retryable != tt.retryable {
+				t.Errorf("retryable = %v, want %v (err=%v)", retryable, tt.retryable, err)
+			}
+		})
+	}
+}
+
+func TestOpsgeniePriority(t *testing.T) {
+	for in, want := range map[string]string{
+		"Critical": "P1",
+		"High":     "P2",
+		"Medium":   "P3",
+		"":         "P3",
+		"Low":      "P4",
+		"Info":     "P5",
+	} {
+		if got := opsgeniePriority(in); got != want {
+			t.Errorf("opsgeniePriority(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDutyBackend 通过 Events API v2 触发 / 确认 / 恢复 PagerDuty 事件，
+// dedup_key 使用告警指纹，同一规则的重复告警会合并到同一个 incident。
+type PagerDutyBackend struct {
+	RoutingKey string
+	EventsURL  string
+	Source     string
+	ClientURL  string
+	Timeout    time.Duration
+}
+
+func (p *PagerDutyBackend) Name() string { return "pagerduty" }
+
+func (p *PagerDutyBackend) Open(ctx context.Context, inc Incident) error {
+	d := inc.Event
+	ts := d.StartsAt
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	event := map[string]any{
+		"routing_key":  p.RoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    inc.Key,
+		"payload": map[string]any{
+			"summary":        truncateRunes(d.Title, 1024),
+			"source":         incidentSource(p.Source, d),
+			"severity":       pagerDutySeverity(d.Severity),
+			"timestamp":      ts.Format(time.RFC3339),
+			"group":          d.Rule,
+			"class":          "log-alert",
+			"custom_details": incidentDetails(d),
+		},
+		"client": "elasticsearch-alert",
+	}
+	var links []map[string]string
+	if d.Summary.DetailURL != "" {
+		links = append(links, map[string]string{"href": d.Summary.DetailURL, "text": "查看日志详情"})
+	}
+	if d.Summary.DiscoverURL != "" {
+		links = append(links, map[string]string{"href": d.Summary.DiscoverURL, "text": "在 Discover 中查看"})
+	}
+	if d.Summary.RunbookURL != "" {
+		links = append(links, map[string]string{"href": d.Summary.RunbookURL, "text": "Runbook"})
+	}
+	if len(links) > 0 {
+		event["links"] = links
+	}
+	if p.ClientURL != "" {
+		event["client_url"] = p.ClientURL
+	}
+	return p.post(ctx, event)
+}
+
+func (p *PagerDutyBackend) Ack(ctx context.Context, inc Incident) error {
+	return p.post(ctx, map[string]any{
+		"routing_key":  p.RoutingKey,
+		"event_action": "acknowledge",
+		"dedup_key":    inc.Key,
+	})
+}
+
+func (p *PagerDutyBackend) Close(ctx context.Context, inc Incident) error {
+	return p.post(ctx, map[string]any{
+		"routing_key":  p.RoutingKey,
+		"event_action": "resolve",
+		"dedup_key":    inc.Key,
+	})
+}
+
+func (p *PagerDutyBackend) post(ctx context.Context, event map[string]any) error {
+	url := p.EventsURL
+	if url == "" {
+		url = pagerDutyEventsURL
+	}
+	b, _ := json.Marshal(event)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := &http.Client{Timeout: p.Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return statusError("pagerduty", resp, body)
+	}
+	return nil
+}
+
+// pagerDutySeverity 将规则级别映射为 PagerDuty 的 critical / error / warning / info
+func pagerDutySeverity(severity string) string {
+	switch strings.ToLower(severity) {
+	case "critical":
+		return "critical"
+	case "high":
+		return "error"
+	case "low", "info":
+		return "info"
+	default:
+		return "warning"
+	}
+}
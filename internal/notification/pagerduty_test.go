@@ -0,0 +1,170 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// stubServer 记录收到的请求体，并按 handler 返回的状态码与响应头应答
+type stubServer struct {
+	*httptest.Server
+	paths  []string
+	bodies []map[string]any
+}
+
+func newStubServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request) int) *stubServer {
+	t.Helper()
+	s := &stubServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request body: %v", err)
+		}
+		s.paths = append(s.paths, r.URL.RequestURI())
+		s.bodies = append(s.bodies, body)
+		status := http.StatusAccepted
+		if handler != nil {
+			status = handler(w, r)
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func testIncidentEvent(status string) *AlertEvent {
+	return &AlertEvent{
+		Title:       "[Elasticsearch Alert] k8s-error",
+		Rule:        "k8s-error",
+		Index:       "logs-*",
+		Severity:    "High",
+		Status:      status,
+		Fingerprint: "esalert-0123456789abcdef",
+		Labels:      map[string]string{"team": "payment"},
+		StartsAt:    time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		Summary: Summary{
+			Overview:    []Field{{Name: "命中条数", Value: "42"}},
+			Log:         "java.lang.NullPointerException",
+			DetailURL:   "https://alert.example.com/logs?index=logs&id=1",
+			DiscoverURL: "https://kibana.example.com/app/discover#/",
+			RunbookURL:  "https://wiki.example.com/runbook",
+		},
+	}
+}
+
+func TestPagerDutyLifecycle(t *testing.T) {
+	srv := newStubServer(t, nil)
+	n := &IncidentNotifier{Backend: &PagerDutyBackend{RoutingKey: "rk", EventsURL: srv.URL, Timeout: time.Second}}
+
+	for _, status := range []string{StatusFiring, StatusAcked, StatusResolved} {
+		if err := n.Send(context.Background(), testIncidentEvent(status)); err != nil {
+			t.Fatalf("send %s: %v", status, err)
+		}
+	}
+	if len(srv.bodies) != 3 {
+		t.Fatalf("got %d requests, want 3", len(srv.bodies))
+	}
+	for i, action := range []string{"trigger", "acknowledge", "resolve"} {
+		b := srv.bodies[i]
+		if b["event_action"] != action {
+			t.Errorf("request %d: event_action = %v, want %s", i, b["event_action"], action)
+		}
+		if b["dedup_key"] != "esalert-0123456789abcdef" {
+			t.Errorf("request %d: dedup_key = %v, want the fingerprint", i, b["dedup_key"])
+		}
+		if b["routing_key"] != "rk" {
+			t.Errorf("request %d: routing_key = %v", i, b["routing_key"])
+		}
+	}
+
+	trigger := srv.bodies[0]
+	payload := trigger["payload"].(map[string]any)
+	if payload["severity"] != "error" {
+		t.Errorf("severity = %v, want error", payload["severity"])
+	}
+	if payload["timestamp"] != "2024-05-01T10:00:00Z" {
+		t.Errorf("timestamp = %v", payload["timestamp"])
+	}
+	details := payload["custom_details"].(map[string]any)
+	if details["sample_log"] != "java.lang.NullPointerException" || details["label.team"] != "payment" || details["命中条数"] != "42" {
+		t.Errorf("custom_details = %v", details)
+	}
+	if links := trigger["links"].([]any); len(links) != 3 {
+		t.Errorf("links = %v, want detail, discover and runbook", links)
+	}
+	if _, ok := srv.bodies[2]["payload"]; ok {
+		t.Errorf("resolve should not carry a payload")
+	}
+}
+
+func TestPagerDutyMissingDedupKey(t *testing.T) {
+	srv := newStubServer(t, nil)
+	n := &IncidentNotifier{Backend: &PagerDutyBackend{RoutingKey: "rk", EventsURL: srv.URL, Timeout: time.Second}}
+	ev := testIncidentEvent(StatusFiring)
+	ev.Fingerprint = ""
+	err := n.Send(context.Background(), ev)
+	if retryable, _ := classify(err); err == nil || retryable {
+		t.Fatalf("err = %v, want a permanent error", err)
+	}
+	if len(srv.bodies) != 0 {
+		t.Errorf("no request expected without a dedup key")
+	}
+}
+
+func TestPagerDutyErrorClassification(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		wantErr    bool
+		retryable  bool
+		after      time.Duration
+	}{
+		{name: "accepted", status: http.StatusAccepted},
+		{name: "rate limited", status: http.StatusTooManyRequests, retryAfter: "30", wantErr: true, retryable: true, after: 30 * time.Second},
+		{name: "server error", status: http.StatusInternalServerError, wantErr: true, retryable: true},
+		{name: "bad request", status: http.StatusBadRequest, wantErr: true},
+		{name: "forbidden", status: http.StatusForbidden, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newStubServer(t, func(w http.ResponseWriter, r *http.Request) int {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				return tt.status
+			})
+			n := &IncidentNotifier{Backend: &PagerDutyBackend{RoutingKey: "rk", EventsURL: srv.URL, Timeout: time.Second}}
+			err := n.Send(context.Background(), testIncidentEvent(StatusFiring))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				return
+			}
+			retryable, after := classify(err)
+			if retryable != tt.retryable || after != tt.after {
+				t.Errorf("classify = (%v, %s), want (%v, %s)", retryable, after, tt.retryable, tt.after)
+			}
+		})
+	}
+}
+
+func TestPagerDutySeverity(t *testing.T) {
+	for in, want := range map[string]string{
+		"Critical": "critical",
+		"High":     "error",
+		"Medium":   "warning",
+		"":         "warning",
+		"Low":      "info",
+		"info":     "info",
+	} {
+		if got := pagerDutySeverity(in); got != want {
+			t.Errorf("pagerDutySeverity(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package notification
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"elasticsearch-alert/internal/config"
+)
+
+// defaultRateLimits 为各平台文档中的机器人发送频率限制
+var defaultRateLimits = map[string]config.RateLimitConfig{
+	"dingtalk": {PerMinute: 20},
+	"wechat":   {PerMinute: 20},
+	"feishu":   {PerMinute: 100},
+}
+
+const (
+	overflowQueue     = "queue"
+	overflowSummarize = "summarize"
+)
+
+// limiter 是单个渠道的令牌桶
+type limiter struct {
+	rate           float64 // 每秒补充的令牌数
+	burst          float64
+	tokens         float64
+	last           time.Time
+	summarize      bool
+	summarizeAfter int
+}
+
+// newLimiter 根据配置创建渠道限流器，不限流时返回 nil
+func newLimiter(channel string, overrides map[string]config.RateLimitConfig) (*limiter, error) {
+	cfg, ok := overrides[channel]
+	if !ok {
+		cfg = defaultRateLimits[channel]
+	} else if cfg.PerMinute == 0 {
+		cfg.PerMinute = defaultRateLimits[channel].PerMinute
+	}
+	if cfg.PerMinute <= 0 {
+		return nil, nil
+	}
+	burst := cfg.Burst
+	if burst <= 0 {
+		burst = cfg.PerMinute
+	}
+	l := &limiter{
+		rate:           float64(cfg.PerMinute) / 60,
+		burst:          float64(burst),
+		tokens:         float64(burst),
+		summarizeAfter: cfg.SummarizeAfter,
+	}
+	switch strings.ToLower(cfg.Overflow) {
+	case "", overflowQueue:
+	case overflowSummarize:
+		l.summarize = true
+	default:
+		return nil, fmt.Errorf("rateLimits.%s: invalid overflow %q", channel, cfg.Overflow)
+	}
+	if l.summarizeAfter <= 0 {
+		l.summarizeAfter = 5
+	}
+	return l, nil
+}
+
+// take 尝试取出 n 个令牌：成功返回 0，否则返回需要等待的时长（不消耗令牌）。n 超过桶容量时按桶容量计算
+func (l *limiter) take(now time.Time, n int) time.Duration {
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+	need := min(float64(n), l.burst)
+	if l.tokens >= need {
+		l.tokens -= need
+		return 0
+	}
+	return time.Duration((need - l.tokens) / l.rate * float64(time.Second))
+}
+
+// summarizeMessages 将因限流积压的多条通知合并为一条汇总消息，按规则统计条数
+func summarizeMessages(channel string, msgs []*Message) *Message {
+	counts := make(map[string]int)
+	var rules []string
+	total := 0
+	add := func(rule string, n int) {
+		if counts[rule] == 0 {
+			rules = append(rules, rule)
+		}
+		counts[rule] += n
+		total += n
+	}
+	for _, m := range msgs {
+		if m.Merged == nil {
+			add(m.Rule, 1)
+			continue
+		}
+		// 之前已经合并过的汇总消息，展开后重新统计
+		merged := make([]string, 0, len(m.Merged))
+		for r := range m.Merged {
+			merged = append(merged, r)
+		}
+		sort.Strings(merged)
+		for _, r := range merged {
+			add(r, m.Merged[r])
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return counts[rules[i]] > counts[rules[j]] })
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("⚠️ **渠道 %s 触发限流，另有 %d 条告警被合并**\n\n", channel, total))
+	for _, r := range rules {
+		b.WriteString(fmt.Sprintf("- **%s：** %d 条\n", r, counts[r]))
+	}
+	b.WriteString(fmt.Sprintf("\n时间范围：%s ~ %s",
+		msgs[0].CreatedAt.Format("2006-01-02 15:04:05"),
+		msgs[len(msgs)-1].CreatedAt.Format("2006-01-02 15:04:05")))
+
+	// 沿用第一条告警的事件信息（级别、收件人等），正文替换为汇总内容；汇总消息不对应任何一条告警，不带告警标识
+	ev := *msgs[0].Event
+	ev.Status = StatusFiring
+	ev.Fingerprint = ""
+	ev.Delivered = nil
+	ev.Rule = strings.Join(rules, ",")
+	ev.Title = fmt.Sprintf("[Elasticsearch Alert] %d more alerts suppressed", total)
+	ev.Text = b.String()
+	ev.Count = total
+	ev.Samples = nil
+	ev.Summary = Summary{Description: b.String()}
+
+	now := time.Now()
+	return &Message{
+		ID:        newMessageID(now),
+		Channel:   channel,
+		Rule:      ev.Rule,
+		Event:     &ev,
+		CreatedAt: msgs[0].CreatedAt,
+		NextAt:    now,
+		Merged:    counts,
+	}
+}
@@ -0,0 +1,69 @@
+package notification
+
+import "time"
+
+// AlertRecord 是告警的结构化记录，面向数据平台 / SIEM 等机器消费方（CloudEvents 的 data、syslog 与文件输出），
+// 不包含渲染好的 Markdown 与收件人信息。
+type AlertRecord struct {
+	Rule        string            `json:"rule"`
+	Title       string            `json:"title"`
+	Description string            `json:"description,omitempty"`
+	Index       string            `json:"index,omitempty"`
+	Severity    string            `json:"severity,omitempty"`
+	Status      string            `json:"status"`
+	Fingerprint string            `json:"fingerprint,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Count       int               `json:"count"`
+	Threshold   *int              `json:"threshold,omitempty"`
+	TimeWindow  string            `json:"timeWindow,omitempty"`
+	WindowStart *time.Time        `json:"windowStart,omitempty"`
+	WindowEnd   *time.Time        `json:"windowEnd,omitempty"`
+	Samples     []map[string]any  `json:"samples,omitempty"`
+	EvaluatedAt time.Time         `json:"evaluatedAt"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      *time.Time        `json:"endsAt,omitempty"`
+	AckedAt     *time.Time        `json:"ackedAt,omitempty"`
+	AckedBy     string            `json:"ackedBy,omitempty"`
+	DetailURL   string            `json:"detailURL,omitempty"`
+	RunbookURL  string            `json:"runbookURL,omitempty"`
+	DiscoverURL string            `json:"discoverURL,omitempty"`
+}
+
+func newAlertRecord(ev *AlertEvent) AlertRecord {
+	rec := AlertRecord{
+		Rule:        ev.Rule,
+		Title:       ev.Title,
+		Description: ev.Description,
+		Index:       ev.Index,
+		Severity:    ev.Severity,
+		Status:      ev.Status,
+		Fingerprint: ev.Fingerprint,
+		Labels:      ev.Labels,
+		Count:       ev.Count,
+		Threshold:   ev.Threshold,
+		TimeWindow:  ev.TimeWindow,
+		Samples:     ev.Samples,
+		EvaluatedAt: ev.EvaluatedAt,
+		StartsAt:    ev.StartsAt,
+		AckedBy:     ev.AckedBy,
+		DetailURL:   ev.Summary.DetailURL,
+		RunbookURL:  ev.Summary.RunbookURL,
+		DiscoverURL: ev.Summary.DiscoverURL,
+	}
+	if rec.Status == "" {
+		rec.Status = StatusFiring
+	}
+	if rec.EvaluatedAt.IsZero() {
+		rec.EvaluatedAt = time.Now()
+	}
+	if !ev.EndsAt.IsZero() {
+		rec.EndsAt = &ev.EndsAt
+	}
+	if !ev.AckedAt.IsZero() {
+		rec.AckedAt = &ev.AckedAt
+	}
+	if !ev.WindowStart.IsZero() {
+		rec.WindowStart, rec.WindowEnd = &ev.WindowStart, &ev.WindowEnd
+	}
+	return rec
+}
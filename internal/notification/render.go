@@ -0,0 +1,216 @@
+package notification
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// minLogBytes 截断后剩余的日志不足该长度时直接省略
+const minLogBytes = 64
+
+// RenderMarkdown 将结构化内容渲染为各渠道通用的 Markdown 正文
+func RenderMarkdown(status string, sum Summary, hasSample bool) string {
+	var b strings.Builder
+	// 标题行（正文内部的视觉标题，各渠道外层也有标题）
+	switch status {
+	case StatusResolved:
+		b.WriteString("✅ **Elasticsearch 日志告警已恢复**\n\n")
+	case StatusAcked:
+		b.WriteString("👀 **Elasticsearch 日志告警已确认**\n\n")
+	default:
+		b.WriteString("🚨 **Elasticsearch 日志告警**\n\n")
+	}
+
+	if sum.Description != "" {
+		b.WriteString(sum.Description + "\n\n")
+	}
+
+	// 概览信息
+	b.WriteString("📊 **告警概览**\n")
+	for _, f := range sum.Overview {
+		b.WriteString(fmt.Sprintf("- **%s：** %s\n", f.Name, f.Value))
+	}
+
+	if hasSample {
+		b.WriteString("\n📌 **本次告警目标**\n")
+		for _, f := range sum.Target {
+			b.WriteString(fmt.Sprintf("- **%s：** %s\n", f.Name, f.Value))
+		}
+	}
+
+	if sum.Log != "" {
+		b.WriteString("\n🧾 **错误日志**\n")
+		b.WriteString(sum.Log)
+		if sum.LogTruncated {
+			b.WriteString("\n...(日志内容较长，已截断显示)")
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString(detailLinkMarkdown(sum))
+
+	// 方便在通知模版底部额外追加 @所有人，这里不直接处理 @ 文本
+	return b.String()
+}
+
+func detailLinkMarkdown(sum Summary) string {
+	if sum.DetailURL == "" && sum.DiscoverURL == "" {
+		return ""
+	}
+	s := "\n"
+	if sum.DetailURL != "" {
+		s += "🔗 **详细日志链接：** " + sum.DetailURL + "\n"
+	}
+	if sum.DiscoverURL != "" {
+		s += "🔍 **Discover 查询链接：** " + sum.DiscoverURL + "\n"
+	}
+	return s
+}
+
+// fitEvent 按渠道的大小预算（字节）收缩告警正文，返回的事件与原事件互不影响。
+// 依次省略样例（样例文档与告警目标）、错误日志中的堆栈、日志本身、描述，
+// 概览与详细日志 / Discover 链接始终保留；仍然超出时按字符边界截断正文并在末尾补上链接。
+func fitEvent(ev *AlertEvent, budget int) *AlertEvent {
+	if budget <= 0 || len(ev.Text) <= budget {
+		return ev
+	}
+	out := *ev
+	// 限流汇总等不是由 Summary 渲染的正文，只能直接截断
+	if len(ev.Summary.Overview) == 0 {
+		out.Text = truncateBytes(ev.Text, budget)
+		return &out
+	}
+
+	sum := ev.Summary
+	render := func() string { return RenderMarkdown(ev.Status, sum, len(sum.Target) > 0) }
+	done := func() *AlertEvent {
+		out.Summary = sum
+		out.Text = render()
+		return &out
+	}
+
+	// 1. 样例
+	out.Samples = nil
+	sum.Target = nil
+	if len(render()) <= budget {
+		return done()
+	}
+
+	// 2. 堆栈，然后按剩余空间截断日志
+	if stripped := stripStackTrace(sum.Log); stripped != sum.Log {
+		sum.Log = stripped
+		sum.LogTruncated = true
+		if len(render()) <= budget {
+			return done()
+		}
+	}
+	if sum.Log != "" {
+		sum.LogTruncated = true
+		log := sum.Log
+		sum.Log = "-"
+		room := budget - (len(render()) - 1)
+		if room >= minLogBytes {
+			sum.Log = truncateBytes(log, room)
+		} else {
+			sum.Log, sum.LogTruncated = "", false
+		}
+		if len(render()) <= budget {
+			return done()
+		}
+	}
+
+	// 3. 描述
+	if sum.Description != "" {
+		desc := sum.Description
+		sum.Description = "-"
+		room := budget - (len(render()) - 1)
+		if room >= minLogBytes {
+			sum.Description = truncateBytes(desc, room)
+		} else {
+			sum.Description = ""
+		}
+		if len(render()) <= budget {
+			return done()
+		}
+	}
+
+	// 4. 概览本身超出预算：截断正文，保留链接
+	out.Summary = sum
+	link := detailLinkMarkdown(sum)
+	text := render()
+	// 预算连链接都放不下时只能整体截断
+	if len(link) >= budget {
+		out.Text = truncateBytes(text, budget)
+		return &out
+	}
+	out.Text = truncateBytes(strings.TrimSuffix(text, link), budget-len(link)) + link
+	return &out
+}
+
+func maxBytesDefault(configured, def int) int {
+	if configured > 0 {
+		return configured
+	}
+	return def
+}
+
+// truncateRunes 按字符（而非字节）截断，避免截断多字节字符
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// truncateBytes 在不超过 n 字节的前提下按字符边界截断，截断时以 "…" 结尾
+func truncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	const ellipsis = "…"
+	n -= len(ellipsis)
+	if n <= 0 {
+		return ""
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + ellipsis
+}
+
+// stackFrame 匹配常见语言的堆栈帧：Java / JS 的 "at ..."、Python 的 File "...", line N、Go 的 file.go:N 等
+var stackFrame = regexp.MustCompile(`^(\s+at\s|\s*File ".*", line \d+|\s+\S+\.go:\d+|\s*\.\.\. \d+ (more|common frames omitted)|goroutine \d+ \[)`)
+
+// stripStackTrace 去掉日志中的堆栈帧，保留异常信息与 Caused by 等行
+func stripStackTrace(log string) string {
+	lines := strings.Split(log, "\n")
+	out := make([]string, 0, len(lines))
+	omitted := 0
+	inPythonFrame := false
+	for _, l := range lines {
+		if stackFrame.MatchString(l) {
+			omitted++
+			inPythonFrame = strings.Contains(l, `File "`)
+			continue
+		}
+		// Python 堆栈帧的下一行是缩进的源码
+		if inPythonFrame && strings.HasPrefix(l, "    ") {
+			omitted++
+			inPythonFrame = false
+			continue
+		}
+		inPythonFrame = false
+		if omitted > 0 {
+			out = append(out, fmt.Sprintf("\t...(省略 %d 行堆栈)", omitted))
+			omitted = 0
+		}
+		out = append(out, l)
+	}
+	if omitted > 0 {
+		out = append(out, fmt.Sprintf("\t...(省略 %d 行堆栈)", omitted))
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,239 @@
+package notification
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateBytes(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		n    int
+		want string
+	}{
+		{name: "fits", in: "hello", n: 5, want: "hello"},
+		{name: "ascii", in: "hello world", n: 8, want: "hello…"},
+		// "中" 占 3 字节，"…" 占 3 字节
+		{name: "cjk boundary", in: "中文日志", n: 9, want: "中文…"},
+		{name: "cjk inside rune", in: "中文日志", n: 10, want: "中文…"},
+		{name: "cjk one byte short", in: "中文日志", n: 8, want: "中…"},
+		{name: "emoji", in: "🚨🚨🚨", n: 10, want: "🚨…"},
+		{name: "mixed", in: "a中b文c", n: 7, want: "a中…"},
+		{name: "mixed inside rune", in: "a中b文c", n: 6, want: "a…"},
+		{name: "budget below ellipsis", in: "中文日志", n: 2, want: ""},
+		{name: "budget equals ellipsis", in: "中文日志", n: 3, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateBytes(tt.in, tt.n)
+			if got != tt.want {
+				t.Errorf("truncateBytes(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+			}
+			if len(got) > tt.n || !utf8.ValidString(got) {
+				t.Errorf("truncateBytes(%q, %d) = %q: %d bytes, valid=%v", tt.in, tt.n, got, len(got), utf8.ValidString(got))
+			}
+		})
+	}
+}
+
+func TestTruncateRunes(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{in: "k8s-error", n: 26, want: "k8s-error"},
+		{in: "支付服务错误日志告警", n: 10, want: "支付服务错误日志告警"},
+		{in: "支付服务错误日志告警", n: 5, want: "支付服务…"},
+		{in: "🚨 payment", n: 3, want: "🚨 …"},
+	}
+	for _, tt := range tests {
+		if got := truncateRunes(tt.in, tt.n); got != tt.want {
+			t.Errorf("truncateRunes(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+		}
+	}
+}
+
+// testLongEvent 返回正文很长的告警：中文描述、带堆栈的中文日志与样例
+func testLongEvent() *AlertEvent {
+	var log strings.Builder
+	log.WriteString("支付回调处理失败：订单状态不一致 java.lang.IllegalStateException: 订单不存在\n")
+	for i := 0; i < 200; i++ {
+		log.WriteString("\tat com.example.payment.CallbackHandler.handle(CallbackHandler.java:42)\n")
+	}
+	log.WriteString("Caused by: java.sql.SQLException: 连接池耗尽")
+	sum := Summary{
+		Description: strings.Repeat("支付服务在高峰期出现大量回调错误，请检查下游数据库。", 40),
+		Overview: []Field{
+			{Name: "规则", Value: "payment-callback-error"},
+			{Name: "级别", Value: "High"},
+			{Name: "命中条数", Value: "1024"},
+		},
+		Target:      []Field{{Name: "命名空间", Value: "payment"}, {Name: "Pod", Value: "payment-callback-7d9f"}},
+		Log:         log.String(),
+		DetailURL:   "https://alert.example.com/logs?index=logs&id=1",
+		DiscoverURL: "https://kibana.example.com/app/discover#/?_a=(query:(language:kuery,query:'level:ERROR'))",
+	}
+	return &AlertEvent{
+		Title:   "[Elasticsearch Alert] payment-callback-error",
+		Rule:    "payment-callback-error",
+		Status:  StatusFiring,
+		Summary: sum,
+		Samples: []map[string]any{{"message": "订单不存在"}},
+		Text:    RenderMarkdown(StatusFiring, sum, true),
+	}
+}
+
+func TestFitEvent(t *testing.T) {
+	ev := testLongEvent()
+	original := ev.Text
+	links := len(detailLinkMarkdown(ev.Summary))
+	for budget := 10; budget <= len(original)+100; budget += 37 {
+		out := fitEvent(ev, budget)
+		if len(out.Text) > budget {
+			t.Fatalf("budget %d: text is %d bytes", budget, len(out.Text))
+		}
+		if !utf8.ValidString(out.Text) {
+			t.Fatalf("budget %d: text is not valid UTF-8", budget)
+		}
+		if budget >= len(original) {
+			if out != ev {
+				t.Fatalf("budget %d: event within budget should be returned as is", budget)
+			}
+			continue
+		}
+		// 预算放得下时详细日志与 Discover 链接始终保留
+		if budget > links && !strings.HasSuffix(out.Text, detailLinkMarkdown(ev.Summary)) {
+			t.Fatalf("budget %d: links were dropped:\n%s", budget, out.Text)
+		}
+		if out.Samples != nil {
+			t.Fatalf("budget %d: samples should be dropped first", budget)
+		}
+	}
+	if ev.Text != original || ev.Samples == nil || len(ev.Summary.Target) == 0 {
+		t.Error("fitEvent modified the original event")
+	}
+}
+
+func TestFitEventOrder(t *testing.T) {
+	ev := testLongEvent()
+	withoutSamples := ev.Summary
+	withoutSamples.Target = nil
+	withoutStack := withoutSamples
+	withoutStack.Log = stripStackTrace(withoutStack.Log)
+	withoutStack.LogTruncated = true
+
+	// 去掉样例后仍超出预算，去掉堆栈后放得下：日志保留异常信息，描述完整保留
+	budget := len(RenderMarkdown(StatusFiring, withoutStack, false))
+	out := fitEvent(ev, budget)
+	if out.Summary.Description != ev.Summary.Description {
+		t.Error("description should be kept when dropping the stack trace is enough")
+	}
+	if !strings.Contains(out.Summary.Log, "Caused by: java.sql.SQLException: 连接池耗尽") || strings.Contains(out.Summary.Log, "CallbackHandler.java") {
+		t.Errorf("log = %q, want the stack trace stripped", out.Summary.Log)
+	}
+	if len(out.Summary.Target) != 0 {
+		t.Error("target fields should be dropped together with samples")
+	}
+}
+
+func TestFitEventWithoutSummary(t *testing.T) {
+	// 限流汇总等没有 Summary 的正文直接按字符边界截断
+	ev := &AlertEvent{Text: strings.Repeat("限流汇总", 100)}
+	out := fitEvent(ev, 100)
+	if len(out.Text) > 100 || !utf8.ValidString(out.Text) || !strings.HasSuffix(out.Text, "…") {
+		t.Errorf("text = %q (%d bytes)", out.Text, len(out.Text))
+	}
+}
+
+func TestChannelSizeLimits(t *testing.T) {
+	ev := testLongEvent()
+	ev.Summary.Log = strings.Repeat("数据库连接超时，重试失败。", 2000)
+	ev.Text = RenderMarkdown(ev.Status, ev.Summary, true)
+
+	t.Run("wechat", func(t *testing.T) {
+		w := &WeChatNotifier{}
+		content := w.message(ev)["markdown"].(map[string]string)["content"]
+		if len(content) > weChatMaxBytes || !utf8.ValidString(content) {
+			t.Errorf("content is %d bytes (limit %d), valid=%v", len(content), weChatMaxBytes, utf8.ValidString(content))
+		}
+		w.MaxBytes = 1000
+		content = w.message(ev)["markdown"].(map[string]string)["content"]
+		if len(content) > 1000 {
+			t.Errorf("content is %d bytes, want the configured maxBytes 1000", len(content))
+		}
+	})
+	t.Run("wechat app", func(t *testing.T) {
+		w := &WeChatNotifier{CorpID: "ww0123456789"}
+		content := w.message(ev)["markdown"].(map[string]string)["content"]
+		if len(content) > weChatAppMaxBytes || !utf8.ValidString(content) {
+			t.Errorf("content is %d bytes (limit %d), valid=%v", len(content), weChatAppMaxBytes, utf8.ValidString(content))
+		}
+		if len(content) < weChatAppMaxBytes/2 {
+			t.Errorf("content is %d bytes, the budget is not used", len(content))
+		}
+	})
+	t.Run("telegram", func(t *testing.T) {
+		// emoji 占两个 UTF-16 码元，按字符计数会超出 4096 的上限
+		text := strings.Repeat("🚨 pod 重启 🔥\n", 600) + strings.Repeat("🔥", 5000)
+		for _, mode := range []string{parseModeHTML, parseModeMarkdownV2} {
+			parts := telegramFormat(text, mode, telegramMessageLimit)
+			if len(parts) < 2 {
+				t.Errorf("%s: got %d parts, want the message split", mode, len(parts))
+			}
+			for i, p := range parts {
+				if n := utf16Len(p); n > telegramMessageLimit || !utf8.ValidString(p) {
+					t.Errorf("%s: part %d is %d UTF-16 units (limit %d), valid=%v", mode, i, n, telegramMessageLimit, utf8.ValidString(p))
+				}
+			}
+		}
+	})
+	t.Run("dingtalk", func(t *testing.T) {
+		footer := "\n\n@13800000000"
+		_, text := dingTalkContent(ev, 0, footer)
+		if len(text) > dingTalkMaxBytes || !utf8.ValidString(text) || !strings.HasSuffix(text, footer) {
+			t.Errorf("text is %d bytes (limit %d), valid=%v", len(text), dingTalkMaxBytes, utf8.ValidString(text))
+		}
+		_, text = dingTalkContent(ev, 2000, footer)
+		if len(text) > 2000 || !strings.HasSuffix(text, footer) {
+			t.Errorf("text is %d bytes, want the configured maxBytes 2000 with the mention footer", len(text))
+		}
+	})
+}
+
+func TestMentionsOnlyWhenFiring(t *testing.T) {
+	oncall := []Recipient{{Name: "张三", Phone: "13800000000", DingTalkMobile: "13800000000", DingTalkUserID: "ding-zhangsan", WeChatUserID: "zhangsan"}}
+	w := &WeChatNotifier{EnableAtAll: true, MentionedList: []string{"lisi"}}
+	d := &DingTalkNotifier{EnableAtAll: true, MessageType: DingTalkActionCard}
+
+	for _, status := range []string{StatusFiring, StatusResolved, StatusAcked} {
+		ev := &AlertEvent{Title: "[Elasticsearch Alert] k8s-error", Status: status, Severity: "High", Recipients: oncall}
+		ev.Summary.DetailURL = "https://kibana.example.com/doc"
+		mentioned, mobiles := w.mentions(ev)
+		isAtAll, atMobiles, atUserIDs := d.mentions(ev)
+
+		if status != StatusFiring {
+			if len(mentioned) > 0 || len(mobiles) > 0 || isAtAll || len(atMobiles) > 0 || len(atUserIDs) > 0 {
+				t.Errorf("%s: wechat %v %v, dingtalk %v %v %v, want no mentions", status, mentioned, mobiles, isAtAll, atMobiles, atUserIDs)
+			}
+			if w.Cost(ev) != 1 || d.Cost(ev) != 1 {
+				t.Errorf("%s: cost wechat=%d dingtalk=%d, want 1", status, w.Cost(ev), d.Cost(ev))
+			}
+			continue
+		}
+		if strings.Join(mentioned, ",") != "lisi,zhangsan,@all" || strings.Join(mobiles, ",") != "13800000000" {
+			t.Errorf("wechat mentions = %v %v", mentioned, mobiles)
+		}
+		if !isAtAll || strings.Join(atMobiles, ",") != "13800000000" || strings.Join(atUserIDs, ",") != "ding-zhangsan" {
+			t.Errorf("dingtalk mentions = %v %v %v", isAtAll, atMobiles, atUserIDs)
+		}
+		if w.Cost(ev) != 2 || d.Cost(ev) != 2 {
+			t.Errorf("cost wechat=%d dingtalk=%d, want 2", w.Cost(ev), d.Cost(ev))
+		}
+		if got := mentionText(ev); got != "🚨 [Elasticsearch Alert] k8s-error，请及时处理" {
+			t.Errorf("mentionText = %q", got)
+		}
+	}
+}
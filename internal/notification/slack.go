@@ -0,0 +1,291 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"elasticsearch-alert/internal/logging"
+)
+
+// SlackNotifier 使用 Block Kit 渲染告警。配置 Token 时通过 chat.postMessage 发送，
+// 支持按规则覆盖频道，并把同一次告警（从触发到恢复）的后续通知回复到首条消息的消息串中；否则使用 Incoming Webhook。
+type SlackNotifier struct {
+	Webhook         string
+	Token           string
+	Channel         string
+	APIURL          string
+	Timeout         time.Duration
+	ThreadTTL       time.Duration
+	ThreadStateFile string
+
+	mu      sync.Mutex
+	threads map[string]slackThread
+}
+
+// slackThread 记录一次告警在某个频道中的首条消息，后续通知回复到该消息串
+type slackThread struct {
+	TS     string    `json:"ts"`
+	PostAt time.Time `json:"postAt"`
+}
+
+const slackTextLimit = 3000
+
+func (s *SlackNotifier) Name() string { return "slack" }
+
+func (s *SlackNotifier) Send(ctx context.Context, ev *AlertEvent) error {
+	blocks := slackBlocks(ev)
+	if s.Token == "" {
+		return s.postWebhook(ctx, statusIcon(ev.Status)+" "+ev.Title, blocks)
+	}
+
+	channel := s.Channel
+	if ev.SlackChannel != "" {
+		channel = ev.SlackChannel
+	}
+	if channel == "" {
+		return Permanent(fmt.Errorf("slack: channel required in token mode"))
+	}
+	key := slackThreadKey(ev, channel)
+	threadTS := s.thread(key)
+
+	payload := map[string]any{
+		"channel": channel,
+		"text":    statusIcon(ev.Status) + " " + ev.Title,
+		"blocks":  blocks,
+	}
+	if threadTS != "" {
+		payload["thread_ts"] = threadTS
+	}
+	ts, err := s.postMessage(ctx, payload)
+	if err != nil {
+		return err
+	}
+	if threadTS == "" && ev.Rule != "" {
+		s.saveThread(key, ts)
+	}
+	return nil
+}
+
+// slackThreadKey 按告警标识与开始时间区分每一次告警，同一规则的不同告警使用不同的消息串
+func slackThreadKey(ev *AlertEvent, channel string) string {
+	id := ev.Fingerprint
+	if id == "" {
+		id = ev.Rule
+	}
+	return fmt.Sprintf("%s|%d|%s", id, ev.StartsAt.Unix(), channel)
+}
+
+func (s *SlackNotifier) postWebhook(ctx context.Context, text string, blocks []map[string]any) error {
+	payload := map[string]any{"text": text, "blocks": blocks}
+	b, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Webhook, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := &http.Client{Timeout: s.Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return statusError("slack webhook", resp, body)
+	}
+	return nil
+}
+
+// postMessage 调用 chat.postMessage，返回消息的 ts
+func (s *SlackNotifier) postMessage(ctx context.Context, payload map[string]any) (string, error) {
+	b, _ := json.Marshal(payload)
+	apiURL := strings.TrimRight(s.APIURL, "/")
+	if apiURL == "" {
+		apiURL = "https://slack.com/api"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL+"/chat.postMessage", bytes.NewReader(b))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+s.Token)
+	client := &http.Client{Timeout: s.Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return "", statusError("slack chat.postMessage", resp, body)
+	}
+
+	// Web API 出错时同样返回 200，需要检查 ok 字段
+	var res struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+		TS    string `json:"ts"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return "", fmt.Errorf("slack chat.postMessage: decode response: %w", err)
+	}
+	if !res.OK {
+		err := fmt.Errorf("slack chat.postMessage error=%s", res.Error)
+		switch res.Error {
+		case "ratelimited", "internal_error", "fatal_error", "service_unavailable", "request_timeout":
+			return "", Temporary(err, 0)
+		default:
+			return "", Permanent(err)
+		}
+	}
+	return res.TS, nil
+}
+
+func (s *SlackNotifier) thread(key string) string {
+	if s.ThreadTTL <= 0 {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadThreads()
+	t, ok := s.threads[key]
+	if !ok || time.Since(t.PostAt) > s.ThreadTTL {
+		return ""
+	}
+	return t.TS
+}
+
+func (s *SlackNotifier) saveThread(key, ts string) {
+	if s.ThreadTTL <= 0 || ts == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadThreads()
+	s.threads[key] = slackThread{TS: ts, PostAt: time.Now()}
+	for k, t := range s.threads {
+		if time.Since(t.PostAt) > s.ThreadTTL {
+			delete(s.threads, k)
+		}
+	}
+	if s.ThreadStateFile == "" {
+		return
+	}
+	data, _ := json.Marshal(s.threads)
+	if err := writeFileAtomic(s.ThreadStateFile, data); err != nil {
+		logging.Errorf("保存 Slack 消息串状态失败: %v", err)
+	}
+}
+
+// loadThreads 首次使用时从状态文件恢复消息串，调用方需持有 s.mu
+func (s *SlackNotifier) loadThreads() {
+	if s.threads != nil {
+		return
+	}
+	s.threads = make(map[string]slackThread)
+	if s.ThreadStateFile == "" {
+		return
+	}
+	data, err := os.ReadFile(s.ThreadStateFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logging.Errorf("读取 Slack 消息串状态失败: %v", err)
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &s.threads); err != nil {
+		logging.Errorf("解析 Slack 消息串状态失败: %v", err)
+	}
+}
+
+// slackBlocks 将告警结构化内容渲染为 Block Kit：概览字段、代码块形式的错误日志、跳转按钮
+func slackBlocks(ev *AlertEvent) []map[string]any {
+	sum := ev.Summary
+	blocks := []map[string]any{
+		{
+			"type": "header",
+			"text": map[string]any{"type": "plain_text", "text": truncateRunes(statusIcon(ev.Status)+" "+ev.Title, 150), "emoji": true},
+		},
+	}
+	if sum.Description != "" {
+		blocks = append(blocks, slackSection(slackEscape(sum.Description)))
+	}
+	blocks = append(blocks, slackFieldSections(sum.Overview)...)
+	if len(sum.Target) > 0 {
+		blocks = append(blocks, slackSection("*📌 本次告警目标*"))
+		blocks = append(blocks, slackFieldSections(sum.Target)...)
+	}
+	if sum.Log != "" {
+		log := sum.Log
+		if sum.LogTruncated {
+			log += "\n...(日志内容较长，已截断显示)"
+		}
+		// 代码块内容不需要转义，但需要避免提前闭合
+		log = strings.ReplaceAll(log, "```", "'''")
+		blocks = append(blocks, slackSection("*🧾 错误日志*\n```"+truncateRunes(log, slackTextLimit-40)+"```"))
+	}
+	var buttons []map[string]any
+	if sum.DetailURL != "" {
+		buttons = append(buttons, map[string]any{
+			"type":  "button",
+			"text":  map[string]any{"type": "plain_text", "text": "查看日志详情"},
+			"url":   sum.DetailURL,
+			"style": "primary",
+		})
+	}
+	if sum.DiscoverURL != "" {
+		buttons = append(buttons, map[string]any{
+			"type": "button",
+			"text": map[string]any{"type": "plain_text", "text": "在 Discover 中查看"},
+			"url":  sum.DiscoverURL,
+		})
+	}
+	if sum.RunbookURL != "" {
+		buttons = append(buttons, map[string]any{
+			"type": "button",
+			"text": map[string]any{"type": "plain_text", "text": "Runbook"},
+			"url":  sum.RunbookURL,
+		})
+	}
+	if len(buttons) > 0 {
+		blocks = append(blocks, map[string]any{"type": "actions", "elements": buttons})
+	}
+	return blocks
+}
+
+func slackSection(text string) map[string]any {
+	return map[string]any{
+		"type": "section",
+		"text": map[string]any{"type": "mrkdwn", "text": truncateRunes(text, slackTextLimit)},
+	}
+}
+
+// slackFieldSections 每个 section 最多 10 个字段，超出时拆分为多个 section
+func slackFieldSections(fields []Field) []map[string]any {
+	var blocks []map[string]any
+	for i := 0; i < len(fields); i += 10 {
+		end := min(i+10, len(fields))
+		var items []map[string]any
+		for _, f := range fields[i:end] {
+			items = append(items, map[string]any{
+				"type": "mrkdwn",
+				"text": truncateRunes(fmt.Sprintf("*%s*\n%s", slackEscape(f.Name), slackEscape(f.Value)), 2000),
+			})
+		}
+		blocks = append(blocks, map[string]any{"type": "section", "fields": items})
+	}
+	return blocks
+}
+
+// slackEscape 转义 mrkdwn 中的控制字符
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
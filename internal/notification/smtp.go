@@ -0,0 +1,213 @@
+package notification
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// STARTTLS 策略
+const (
+	StartTLSOpportunistic = "opportunistic"
+	StartTLSRequired      = "required"
+	StartTLSNone          = "none"
+)
+
+// smtpConn 一个已完成 TLS 与认证的 SMTP 连接
+type smtpConn struct {
+	client   *smtp.Client
+	conn     net.Conn
+	lastUsed time.Time
+}
+
+func (c *smtpConn) close() {
+	c.client.Close()
+}
+
+// quit 发送 QUIT 后关闭连接；服务器无响应时最多等待 timeout，QUIT 失败也会关闭连接
+func (c *smtpConn) quit(timeout time.Duration) {
+	c.conn.SetDeadline(time.Now().Add(timeout))
+	if err := c.client.Quit(); err != nil {
+		c.close()
+	}
+}
+
+// smtpPool 复用 SMTP 连接，避免每封邮件都重新建立 TCP 连接并完成 TLS 握手与认证。
+// 取出空闲连接时先发送 NOOP 确认连接仍然可用，超过 idleTimeout 的连接直接关闭。
+type smtpPool struct {
+	dial        func(ctx context.Context) (*smtpConn, error)
+	maxIdle     int
+	idleTimeout time.Duration
+	// timeout NOOP 探测与 QUIT 的读写超时，服务器无响应时不会一直阻塞
+	timeout time.Duration
+
+	mu   sync.Mutex
+	idle []*smtpConn
+}
+
+// get 返回一个可用连接，reused 表示连接来自连接池
+func (p *smtpPool) get(ctx context.Context) (c *smtpConn, reused bool, err error) {
+	for {
+		p.mu.Lock()
+		if len(p.idle) == 0 {
+			p.mu.Unlock()
+			break
+		}
+		c = p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		p.mu.Unlock()
+
+		if time.Since(c.lastUsed) > p.idleTimeout {
+			c.quit(p.timeout)
+			continue
+		}
+		c.conn.SetDeadline(time.Now().Add(p.timeout))
+		if err := c.client.Noop(); err != nil {
+			c.close()
+			continue
+		}
+		return c, true, nil
+	}
+	c, err = p.dial(ctx)
+	return c, false, err
+}
+
+// put 将发送完成的连接放回连接池
+func (p *smtpPool) put(c *smtpConn) {
+	if err := c.client.Reset(); err != nil {
+		c.close()
+		return
+	}
+	c.conn.SetDeadline(time.Time{})
+	c.lastUsed = time.Now()
+	p.mu.Lock()
+	if len(p.idle) < p.maxIdle {
+		p.idle = append(p.idle, c)
+		c = nil
+	}
+	p.mu.Unlock()
+	if c != nil {
+		c.quit(p.timeout)
+	}
+}
+
+// dialSMTP 建立连接：隐式 TLS 或按策略执行 STARTTLS，然后认证
+func (e *EmailNotifier) dialSMTP(ctx context.Context) (*smtpConn, error) {
+	addr := fmt.Sprintf("%s:%d", e.Host, e.Port)
+	tlsCfg := &tls.Config{
+		ServerName:         e.Host,
+		InsecureSkipVerify: e.TLSSkipVerify,
+	}
+	dialer := net.Dialer{Timeout: e.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(e.Timeout))
+	if e.UseTLS {
+		tlsConn := tls.Client(conn, tlsCfg)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	c, err := smtp.NewClient(conn, e.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	sc := &smtpConn{client: c, conn: conn}
+
+	if !e.UseTLS && e.StartTLS != StartTLSNone {
+		ok, _ := c.Extension("STARTTLS")
+		switch {
+		case ok:
+			if err := c.StartTLS(tlsCfg); err != nil {
+				sc.close()
+				return nil, err
+			}
+		case e.StartTLS == StartTLSRequired:
+			sc.close()
+			return nil, Permanent(fmt.Errorf("email: %s does not advertise STARTTLS", addr))
+		}
+	}
+	if e.Username != "" {
+		auth, err := e.smtpAuth(c)
+		if err != nil {
+			sc.close()
+			return nil, err
+		}
+		if err := c.Auth(auth); err != nil {
+			sc.close()
+			return nil, err
+		}
+	}
+	return sc, nil
+}
+
+// smtpAuth 按配置选择认证方式；未配置时按服务器声明的 AUTH 依次尝试 PLAIN、LOGIN、CRAM-MD5
+func (e *EmailNotifier) smtpAuth(c *smtp.Client) (smtp.Auth, error) {
+	mechanism := strings.ToLower(e.AuthMechanism)
+	if mechanism == "" {
+		_, params := c.Extension("AUTH")
+		advertised := strings.Fields(strings.ToUpper(params))
+		switch {
+		case containsString(advertised, "PLAIN"):
+			mechanism = "plain"
+		case containsString(advertised, "LOGIN"):
+			mechanism = "login"
+		case containsString(advertised, "CRAM-MD5"):
+			mechanism = "cram-md5"
+		default:
+			mechanism = "plain"
+		}
+	}
+	switch mechanism {
+	case "plain":
+		return smtp.PlainAuth("", e.Username, e.Password, e.Host), nil
+	case "login":
+		return &loginAuth{username: e.Username, password: e.Password, host: e.Host}, nil
+	case "cram-md5":
+		return smtp.CRAMMD5Auth(e.Username, e.Password), nil
+	}
+	return nil, Permanent(fmt.Errorf("email: unsupported auth mechanism %q", e.AuthMechanism))
+}
+
+// loginAuth 实现 AUTH LOGIN（Exchange 等服务器常用），与 PlainAuth 一样只允许在 TLS 或本机连接上发送密码
+type loginAuth struct {
+	username, password, host string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("email: unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("email: wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("email: unexpected LOGIN challenge %q", fromServer)
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
@@ -0,0 +1,125 @@
+package notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"elasticsearch-alert/internal/logging"
+)
+
+// spool 将待发送通知与死信落盘：每条待发送通知一个 JSON 文件，死信保存在单个 JSON 文件中。
+// nil spool 表示不落盘，所有方法均为空操作。
+type spool struct {
+	queueDir       string
+	deadLetterFile string
+}
+
+func newSpool(dir string) (*spool, error) {
+	queueDir := filepath.Join(dir, "queue")
+	if err := os.MkdirAll(queueDir, 0o755); err != nil {
+		return nil, fmt.Errorf("create spool dir: %w", err)
+	}
+	return &spool{
+		queueDir:       queueDir,
+		deadLetterFile: filepath.Join(dir, "deadletter.json"),
+	}, nil
+}
+
+func (s *spool) save(m *Message) error {
+	if s == nil {
+		return nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.queueDir, m.ID+".json"), data)
+}
+
+func (s *spool) remove(id string) {
+	if s == nil {
+		return
+	}
+	if err := os.Remove(filepath.Join(s.queueDir, id+".json")); err != nil && !os.IsNotExist(err) {
+		logging.Errorf("删除落盘通知 %s 失败: %v", id, err)
+	}
+}
+
+// load 读取所有待发送通知，按创建时间排序
+func (s *spool) load() ([]*Message, error) {
+	entries, err := os.ReadDir(s.queueDir)
+	if err != nil {
+		return nil, fmt.Errorf("read spool dir: %w", err)
+	}
+	var msgs []*Message
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(s.queueDir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read spool file %s: %w", path, err)
+		}
+		var m Message
+		if err := json.Unmarshal(data, &m); err != nil {
+			logging.Errorf("落盘通知 %s 已损坏，跳过: %v", path, err)
+			continue
+		}
+		if m.Event == nil {
+			logging.Errorf("落盘通知 %s 缺少告警事件，跳过", path)
+			continue
+		}
+		msgs = append(msgs, &m)
+	}
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].CreatedAt.Before(msgs[j].CreatedAt) })
+	return msgs, nil
+}
+
+func (s *spool) saveDeadLetters(msgs []Message) error {
+	if s == nil {
+		return nil
+	}
+	data, err := json.MarshalIndent(msgs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.deadLetterFile, data)
+}
+
+func (s *spool) loadDeadLetters() ([]Message, error) {
+	data, err := os.ReadFile(s.deadLetterFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read dead letters: %w", err)
+	}
+	var msgs []Message
+	if err := json.Unmarshal(data, &msgs); err != nil {
+		return nil, fmt.Errorf("unmarshal dead letters: %w", err)
+	}
+	// 缺少告警事件的死信无法重发，直接丢弃
+	kept := msgs[:0]
+	for _, m := range msgs {
+		if m.Event == nil {
+			logging.Errorf("死信 %s 缺少告警事件，丢弃", m.ID)
+			continue
+		}
+		kept = append(kept, m)
+	}
+	return kept, nil
+}
+
+// writeFileAtomic 先写临时文件再重命名，避免进程退出时留下半个文件
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,298 @@
+package notification
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"elasticsearch-alert/internal/config"
+)
+
+const (
+	syslogAppName = "elasticsearch-alert"
+	syslogSDID    = "alert@32473"
+	// syslogMaxDatagram UDP / unixgram 单条报文的上限，超出时省略样本
+	syslogMaxDatagram = 8192
+)
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11, "ntp": 12, "security": 13, "console": 14,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// SyslogNotifier 以 RFC 5424 格式将告警写入 syslog。告警的关键字段放在结构化数据中，
+// MSG 为告警的 JSON 记录。支持 UDP、TCP / TLS（octet counting 分帧，RFC 6587）与本地 unix socket，
+// 连接在多次发送间复用，写入失败时重连一次，仍失败则交给分发器重试。
+type SyslogNotifier struct {
+	Network   string
+	Address   string
+	Facility  int
+	AppName   string
+	Hostname  string
+	SDID      string
+	TLSConfig *tls.Config
+	Timeout   time.Duration
+
+	mu       sync.Mutex
+	conn     net.Conn
+	stream   bool
+	procID   string
+	hostname string
+}
+
+// NewSyslogNotifier 根据配置创建 syslog 渠道，不会立即建立连接
+func NewSyslogNotifier(cfg config.SyslogConfig) (*SyslogNotifier, error) {
+	s := &SyslogNotifier{
+		Network:  strings.ToLower(cfg.Network),
+		Address:  cfg.Address,
+		AppName:  cfg.AppName,
+		Hostname: cfg.Hostname,
+		SDID:     cfg.SDID,
+		Timeout:  parseDurationDefault(cfg.Timeout, 5*time.Second),
+	}
+	if s.Network == "" {
+		s.Network = "udp"
+	}
+	switch s.Network {
+	case "udp", "tcp":
+	case "unix":
+		if s.Address == "" {
+			s.Address = "/dev/log"
+		}
+	case "tls":
+		tlsCfg := &tls.Config{ServerName: cfg.TLSServerName, InsecureSkipVerify: cfg.TLSSkipVerify}
+		if cfg.TLSCAFile != "" {
+			pem, err := os.ReadFile(cfg.TLSCAFile)
+			if err != nil {
+				return nil, fmt.Errorf("syslog: read CA file: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("syslog: no certificates found in %s", cfg.TLSCAFile)
+			}
+			tlsCfg.RootCAs = pool
+		}
+		s.TLSConfig = tlsCfg
+	default:
+		return nil, fmt.Errorf("syslog: unsupported network %q", cfg.Network)
+	}
+	if s.Address == "" {
+		return nil, fmt.Errorf("syslog: address required")
+	}
+
+	facility := strings.ToLower(cfg.Facility)
+	if facility == "" {
+		facility = "local0"
+	}
+	f, ok := syslogFacilities[facility]
+	if !ok {
+		return nil, fmt.Errorf("syslog: unknown facility %q", cfg.Facility)
+	}
+	s.Facility = f
+	if s.AppName == "" {
+		s.AppName = syslogAppName
+	}
+	if s.SDID == "" {
+		s.SDID = syslogSDID
+	}
+	s.hostname = s.Hostname
+	if s.hostname == "" {
+		s.hostname, _ = os.Hostname()
+	}
+	s.procID = strconv.Itoa(os.Getpid())
+	return s, nil
+}
+
+func (s *SyslogNotifier) Name() string { return "syslog" }
+
+func (s *SyslogNotifier) WantsResolved() bool { return true }
+
+func (s *SyslogNotifier) WantsAcked() bool { return true }
+
+func (s *SyslogNotifier) Send(ctx context.Context, ev *AlertEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn != nil && s.stream && peerClosed(s.conn) {
+			s.conn.Close()
+			s.conn = nil
+		}
+		if s.conn == nil {
+			if err = s.dial(ctx); err != nil {
+				return fmt.Errorf("syslog dial %s %s: %w", s.Network, s.Address, err)
+			}
+		}
+		if err = s.write(s.format(ev)); err == nil {
+			return nil
+		}
+		// 连接可能已被对端关闭，丢弃后重连一次
+		s.conn.Close()
+		s.conn = nil
+	}
+	return fmt.Errorf("syslog write %s %s: %w", s.Network, s.Address, err)
+}
+
+func (s *SyslogNotifier) dial(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: s.Timeout}
+	var (
+		conn net.Conn
+		err  error
+	)
+	switch s.Network {
+	case "tls":
+		td := &tls.Dialer{NetDialer: dialer, Config: s.TLSConfig}
+		conn, err = td.DialContext(ctx, "tcp", s.Address)
+		s.stream = true
+	case "unix":
+		// 本地 syslog 守护进程通常监听 unixgram，不支持时退回 unix stream
+		conn, err = dialer.DialContext(ctx, "unixgram", s.Address)
+		s.stream = false
+		if err != nil {
+			conn, err = dialer.DialContext(ctx, "unix", s.Address)
+			s.stream = true
+		}
+	default:
+		conn, err = dialer.DialContext(ctx, s.Network, s.Address)
+		s.stream = s.Network == "tcp"
+	}
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+func (s *SyslogNotifier) write(msg []byte) error {
+	if s.stream {
+		// octet counting：MSG-LEN SP SYSLOG-MSG
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(s.Timeout))
+	_, err := s.conn.Write(msg)
+	return err
+}
+
+// peerClosed 检查流式连接是否已被对端关闭。syslog 服务端不会发送数据，
+// 短暂读取超时说明连接正常，读到 EOF 说明对端已关闭（此时写入仍会“成功”而丢失消息）。
+func peerClosed(conn net.Conn) bool {
+	conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+	defer conn.SetReadDeadline(time.Time{})
+	var buf [1]byte
+	_, err := conn.Read(buf[:])
+	var ne net.Error
+	return err != nil && !(errors.As(err, &ne) && ne.Timeout())
+}
+
+// format 生成 RFC 5424 消息：<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] BOM+JSON
+func (s *SyslogNotifier) format(ev *AlertEvent) []byte {
+	ts := ev.EvaluatedAt
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	status := ev.Status
+	if status == "" {
+		status = StatusFiring
+	}
+	pri := s.Facility*8 + syslogSeverity(ev.Severity, status)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %s %s ",
+		pri,
+		ts.Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogHeaderField(s.hostname, 255),
+		syslogHeaderField(s.AppName, 48),
+		syslogHeaderField(s.procID, 128),
+		syslogHeaderField(status, 32),
+	)
+
+	b.WriteString("[" + s.SDID)
+	param := func(name, value string) {
+		if value != "" {
+			b.WriteString(" " + name + `="` + syslogEscape(value) + `"`)
+		}
+	}
+	param("rule", ev.Rule)
+	param("severity", ev.Severity)
+	param("status", status)
+	param("fingerprint", ev.Fingerprint)
+	param("index", ev.Index)
+	param("count", strconv.Itoa(ev.Count))
+	if ev.Threshold != nil {
+		param("threshold", strconv.Itoa(*ev.Threshold))
+	}
+	param("window", ev.TimeWindow)
+	if !ev.WindowStart.IsZero() {
+		param("windowStart", ev.WindowStart.Format(time.RFC3339))
+		param("windowEnd", ev.WindowEnd.Format(time.RFC3339))
+	}
+	if !ev.StartsAt.IsZero() {
+		param("startsAt", ev.StartsAt.Format(time.RFC3339))
+	}
+	param("ackedBy", ev.AckedBy)
+	b.WriteString("] ")
+
+	rec := newAlertRecord(ev)
+	body, _ := json.Marshal(rec)
+	if !s.stream && b.Len()+len(body) > syslogMaxDatagram {
+		rec.Samples = nil
+		body, _ = json.Marshal(rec)
+	}
+	// MSG 以 UTF-8 BOM 开头，表示内容为 UTF-8
+	b.WriteString("\ufeff")
+	b.Write(body)
+	return []byte(b.String())
+}
+
+// syslogSeverity 将规则级别映射为 syslog severity，恢复与确认统一为 notice
+func syslogSeverity(severity, status string) int {
+	if status != StatusFiring {
+		return 5
+	}
+	switch strings.ToLower(severity) {
+	case "critical":
+		return 2
+	case "high":
+		return 3
+	case "low":
+		return 5
+	case "info":
+		return 6
+	default:
+		return 4
+	}
+}
+
+// syslogHeaderField 头部字段只能是可打印 ASCII 且不含空格，空值用 "-" 表示
+func syslogHeaderField(v string, max int) string {
+	out := make([]byte, 0, len(v))
+	for i := 0; i < len(v) && len(out) < max; i++ {
+		c := v[i]
+		if c < 33 || c > 126 {
+			c = '_'
+		}
+		out = append(out, c)
+	}
+	if len(out) == 0 {
+		return "-"
+	}
+	return string(out)
+}
+
+// syslogEscape 转义结构化数据参数值中的 '"'、'\' 与 ']'
+func syslogEscape(v string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+	return r.Replace(v)
+}
@@ -0,0 +1,152 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// teamsMaxBytes Teams 消息上限约 28KB，卡片由结构化内容渲染，以 Markdown 正文的大小估算
+const teamsMaxBytes = 20000
+
+// TeamsNotifier 通过 Teams Workflows / Incoming Webhook 发送 Adaptive Card
+type TeamsNotifier struct {
+	Webhook     string
+	Timeout     time.Duration
+	TitlePrefix string
+	// MaxBytes 卡片内容的大小预算，超出时按优先级收缩内容
+	MaxBytes int
+}
+
+func (t *TeamsNotifier) Name() string { return "teams" }
+
+func (t *TeamsNotifier) Send(ctx context.Context, ev *AlertEvent) error {
+	ev = fitEvent(ev, maxBytesDefault(t.MaxBytes, teamsMaxBytes))
+	displayTitle := ev.Title
+	if t.TitlePrefix != "" {
+		displayTitle = t.TitlePrefix + " " + ev.Title
+	}
+	payload := map[string]any{
+		"type": "message",
+		"attachments": []map[string]any{
+			{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"contentUrl":  nil,
+				"content":     teamsCard(displayTitle, ev),
+			},
+		},
+	}
+	b, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.Webhook, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := &http.Client{Timeout: t.Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return statusError("teams webhook", resp, body)
+	}
+	return nil
+}
+
+// teamsCard 渲染 Adaptive Card：按级别着色的标题、概览 FactSet、等宽字体的样例日志与跳转按钮
+func teamsCard(title string, d *AlertEvent) map[string]any {
+	sum := d.Summary
+	style, color := teamsSeverityStyle(d.Severity)
+	if d.Status == StatusResolved {
+		style, color = "good", "Good"
+	}
+	body := []map[string]any{
+		{
+			"type":  "Container",
+			"style": style,
+			"bleed": true,
+			"items": []map[string]any{
+				{"type": "TextBlock", "text": statusIcon(d.Status) + " " + title, "size": "Large", "weight": "Bolder", "color": color, "wrap": true},
+			},
+		},
+	}
+	if sum.Description != "" {
+		body = append(body, map[string]any{"type": "TextBlock", "text": sum.Description, "wrap": true})
+	}
+	if len(sum.Overview) > 0 {
+		body = append(body, teamsFactSet(sum.Overview))
+	}
+	if len(sum.Target) > 0 {
+		body = append(body,
+			map[string]any{"type": "TextBlock", "text": "📌 本次告警目标", "weight": "Bolder", "separator": true},
+			teamsFactSet(sum.Target),
+		)
+	}
+	if sum.Log != "" {
+		log := sum.Log
+		if sum.LogTruncated {
+			log += "\n...(日志内容较长，已截断显示)"
+		}
+		body = append(body,
+			map[string]any{"type": "TextBlock", "text": "🧾 错误日志", "weight": "Bolder", "separator": true},
+			map[string]any{
+				"type":  "Container",
+				"style": "emphasis",
+				"items": []map[string]any{
+					{"type": "TextBlock", "text": log, "fontType": "Monospace", "wrap": true, "size": "Small"},
+				},
+			},
+		)
+	}
+
+	var actions []map[string]any
+	if sum.DetailURL != "" {
+		actions = append(actions, map[string]any{"type": "Action.OpenUrl", "title": "查看日志详情", "url": sum.DetailURL})
+	}
+	if sum.DiscoverURL != "" {
+		actions = append(actions, map[string]any{"type": "Action.OpenUrl", "title": "在 Discover 中查看", "url": sum.DiscoverURL})
+	}
+	if sum.RunbookURL != "" {
+		actions = append(actions, map[string]any{"type": "Action.OpenUrl", "title": "Runbook", "url": sum.RunbookURL})
+	}
+
+	card := map[string]any{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"msteams": map[string]any{"width": "Full"},
+		"body":    body,
+	}
+	if len(actions) > 0 {
+		card["actions"] = actions
+	}
+	return card
+}
+
+func teamsFactSet(fields []Field) map[string]any {
+	facts := make([]map[string]string, 0, len(fields))
+	for _, f := range fields {
+		facts = append(facts, map[string]string{"title": f.Name, "value": f.Value})
+	}
+	return map[string]any{"type": "FactSet", "facts": facts}
+}
+
+// teamsSeverityStyle 返回告警级别对应的容器样式与文字颜色
+func teamsSeverityStyle(severity string) (string, string) {
+	switch strings.ToLower(severity) {
+	case "critical", "high":
+		return "attention", "Attention"
+	case "medium":
+		return "warning", "Warning"
+	case "low", "info":
+		return "accent", "Accent"
+	default:
+		return "warning", "Warning"
+	}
+}
@@ -0,0 +1,228 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"elasticsearch-alert/internal/config"
+)
+
+const (
+	// telegramMessageLimit 单条消息的长度上限，按 UTF-16 码元计算（emoji 等占两个）
+	telegramMessageLimit = 4096
+	parseModeHTML        = "HTML"
+	parseModeMarkdownV2  = "MarkdownV2"
+)
+
+// TelegramNotifier 通过 Bot API sendMessage 发送告警，支持多个会话 / 话题，超长消息自动拆分
+type TelegramNotifier struct {
+	BotToken  string
+	Chats     []config.TelegramChat
+	ParseMode string
+	APIURL    string
+	Client    *http.Client
+}
+
+// NewTelegramNotifier 根据配置创建 Telegram 渠道，配置了 proxyURL 时所有请求经代理发送
+func NewTelegramNotifier(cfg config.TelegramConfig) (*TelegramNotifier, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.ProxyURL != "" {
+		u, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("telegram proxyURL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+	mode := parseModeHTML
+	if strings.EqualFold(cfg.ParseMode, parseModeMarkdownV2) {
+		mode = parseModeMarkdownV2
+	}
+	apiURL := strings.TrimRight(cfg.APIURL, "/")
+	if apiURL == "" {
+		apiURL = "https://api.telegram.org"
+	}
+	return &TelegramNotifier{
+		BotToken:  cfg.BotToken,
+		Chats:     cfg.Chats,
+		ParseMode: mode,
+		APIURL:    apiURL,
+		Client: &http.Client{
+			Timeout:   parseDurationDefault(cfg.Timeout, 10*time.Second),
+			Transport: transport,
+		},
+	}, nil
+}
+
+func (t *TelegramNotifier) Name() string { return "telegram" }
+
+func (t *TelegramNotifier) Send(ctx context.Context, ev *AlertEvent) error {
+	parts := telegramFormat(fmt.Sprintf("**%s %s**\n\n%s", statusIcon(ev.Status), ev.Title, ev.Text), t.ParseMode, telegramMessageLimit)
+	// 已送达的会话 / 分段记录在事件中，重试时只发送剩余部分，避免其他会话收到重复消息
+	for _, chat := range t.Chats {
+		for i, part := range parts {
+			key := fmt.Sprintf("telegram:%s:%d:%d", chat.ChatID, chat.ThreadID, i)
+			if ev.delivered(key) {
+				continue
+			}
+			if err := t.sendMessage(ctx, chat, part); err != nil {
+				return fmt.Errorf("telegram chat %s: %w", chat.ChatID, err)
+			}
+			ev.Delivered = append(ev.Delivered, key)
+		}
+	}
+	return nil
+}
+
+func (t *TelegramNotifier) sendMessage(ctx context.Context, chat config.TelegramChat, text string) error {
+	payload := map[string]any{
+		"chat_id":                  chat.ChatID,
+		"text":                     text,
+		"parse_mode":               t.ParseMode,
+		"disable_web_page_preview": true,
+	}
+	if chat.ThreadID != 0 {
+		payload["message_thread_id"] = chat.ThreadID
+	}
+	b, _ := json.Marshal(payload)
+	endpoint := fmt.Sprintf("%s/bot%s/sendMessage", t.APIURL, t.BotToken)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		// 错误信息中可能包含带 token 的 URL，这里只保留原因
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			return ue.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 300 {
+		return nil
+	}
+
+	var res struct {
+		Description string `json:"description"`
+		Parameters  struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"parameters"`
+	}
+	_ = json.Unmarshal(body, &res)
+	if res.Parameters.RetryAfter > 0 {
+		return Temporary(fmt.Errorf("telegram status=%d description=%s", resp.StatusCode, res.Description),
+			time.Duration(res.Parameters.RetryAfter)*time.Second)
+	}
+	return statusError("telegram", resp, body)
+}
+
+// telegramFormat 将通用 Markdown 正文（仅包含 **加粗** 与换行）转换为 Telegram 的 HTML / MarkdownV2，
+// 并按行拆分为不超过 limit 个 UTF-16 码元的多条消息，保证格式标记不会被拆开。
+func telegramFormat(s, mode string, limit int) []string {
+	var parts []string
+	var cur strings.Builder
+	curLen := 0
+	flush := func() {
+		if curLen > 0 {
+			parts = append(parts, cur.String())
+			cur.Reset()
+			curLen = 0
+		}
+	}
+	appendLine := func(line string) {
+		n := utf16Len(line) + 1
+		if curLen+n > limit {
+			flush()
+		}
+		cur.WriteString(line)
+		cur.WriteString("\n")
+		curLen += n
+	}
+	for _, raw := range strings.Split(strings.TrimRight(s, "\n"), "\n") {
+		line := telegramFormatLine(raw, mode, true)
+		if utf16Len(line) < limit {
+			appendLine(line)
+			continue
+		}
+		// 单行过长（通常是日志原文）：去掉格式后按字符切分，转义最多把长度翻倍，因此每段不超过 limit/2 个码元
+		var chunk strings.Builder
+		chunkLen := 0
+		for _, r := range strings.ReplaceAll(raw, "**", "") {
+			n := 1
+			if r > 0xFFFF {
+				n = 2
+			}
+			if chunkLen+n > limit/2 {
+				appendLine(telegramFormatLine(chunk.String(), mode, false))
+				chunk.Reset()
+				chunkLen = 0
+			}
+			chunk.WriteRune(r)
+			chunkLen += n
+		}
+		if chunkLen > 0 {
+			appendLine(telegramFormatLine(chunk.String(), mode, false))
+		}
+	}
+	flush()
+	return parts
+}
+
+// utf16Len 返回字符串的 UTF-16 码元数，Telegram 按此计算消息长度
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+func telegramFormatLine(line, mode string, bold bool) string {
+	segments := []string{line}
+	if bold {
+		segments = strings.Split(line, "**")
+		// 未闭合的 ** 按普通文本处理
+		if len(segments)%2 == 0 {
+			segments = []string{line}
+		}
+	}
+	var b strings.Builder
+	for i, seg := range segments {
+		isBold := i%2 == 1
+		if mode == parseModeMarkdownV2 {
+			if isBold {
+				b.WriteString("*" + telegramEscapeMarkdownV2(seg) + "*")
+			} else {
+				b.WriteString(telegramEscapeMarkdownV2(seg))
+			}
+			continue
+		}
+		if isBold {
+			b.WriteString("<b>" + html.EscapeString(seg) + "</b>")
+		} else {
+			b.WriteString(html.EscapeString(seg))
+		}
+	}
+	return b.String()
+}
+
+// telegramEscapeMarkdownV2 转义 MarkdownV2 中所有需要转义的字符
+func telegramEscapeMarkdownV2(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune("_*[]()~`>#+-=|{}.!\\", r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
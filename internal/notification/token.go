@@ -0,0 +1,45 @@
+package notification
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// tokenRefreshMargin 在 access token 过期前提前刷新
+const tokenRefreshMargin = 5 * time.Minute
+
+// accessToken 缓存飞书 tenant_access_token、企业微信 / 钉钉 access_token 等有效期有限的凭证，
+// 过期前自动重新获取；平台返回凭证失效时调用 Invalidate 强制刷新。
+type accessToken struct {
+	// fetch 获取新的凭证及其有效期
+	fetch func(ctx context.Context) (token string, ttl time.Duration, err error)
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+func (t *accessToken) Get(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" && time.Now().Before(t.expiresAt) {
+		return t.token, nil
+	}
+	token, ttl, err := t.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	if ttl > 2*tokenRefreshMargin {
+		ttl -= tokenRefreshMargin
+	}
+	t.token = token
+	t.expiresAt = time.Now().Add(ttl)
+	return token, nil
+}
+
+func (t *accessToken) Invalidate() {
+	t.mu.Lock()
+	t.token = ""
+	t.mu.Unlock()
+}
@@ -0,0 +1,174 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"elasticsearch-alert/internal/config"
+)
+
+// WebhookNotifier 将告警发送到通用 HTTP 接口。
+// 配置 bodyTemplate 时使用 Go 模板渲染请求体（可访问规则、标签、命中条数、样例与链接），
+// 否则发送默认的 {"title","message","ts"} JSON；可选 HMAC-SHA256 签名与 Basic / Bearer 认证。
+type WebhookNotifier struct {
+	URL             string
+	Method          string
+	Headers         map[string]string
+	ContentType     string
+	Body            *template.Template
+	Secret          string
+	SignatureHeader string
+	TimestampHeader string
+	Username        string
+	Password        string
+	BearerToken     string
+	SuccessCodes    []int
+	Timeout         time.Duration
+}
+
+// WebhookData 是 bodyTemplate 的数据：告警事件的全部字段（.Title / .Rule / .Labels / .Count / .Samples / .Summary.DetailURL 等）
+// 以及发送时间 .Time。
+type WebhookData struct {
+	*AlertEvent
+	Time time.Time
+}
+
+// webhookFuncs 模板函数：json 将任意值编码为 JSON（字符串会带引号并转义），便于拼出合法的 JSON 请求体
+var webhookFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// NewWebhookNotifier 根据配置创建 webhook 渠道，并预先解析请求体模板
+func NewWebhookNotifier(cfg config.WebhookConfig) (*WebhookNotifier, error) {
+	w := &WebhookNotifier{
+		URL:             cfg.URL,
+		Method:          strings.ToUpper(cfg.Method),
+		Headers:         cfg.Headers,
+		ContentType:     cfg.ContentType,
+		Secret:          cfg.Secret,
+		SignatureHeader: cfg.SignatureHeader,
+		TimestampHeader: cfg.TimestampHeader,
+		Username:        cfg.Username,
+		Password:        cfg.Password,
+		BearerToken:     cfg.BearerToken,
+		SuccessCodes:    cfg.SuccessCodes,
+		Timeout:         parseDurationDefault(cfg.Timeout, 5*time.Second),
+	}
+	if w.Method == "" {
+		w.Method = http.MethodPost
+	}
+	if w.ContentType == "" {
+		w.ContentType = "application/json"
+	}
+	if w.SignatureHeader == "" {
+		w.SignatureHeader = "X-Signature"
+	}
+	if w.TimestampHeader == "" {
+		w.TimestampHeader = "X-Signature-Timestamp"
+	}
+	if cfg.BodyTemplate != "" {
+		tmpl, err := template.New("webhook").Funcs(webhookFuncs).Option("missingkey=zero").Parse(cfg.BodyTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("webhook bodyTemplate: %w", err)
+		}
+		w.Body = tmpl
+	}
+	return w, nil
+}
+
+func (w *WebhookNotifier) Name() string { return "webhook" }
+
+func (w *WebhookNotifier) Send(ctx context.Context, ev *AlertEvent) error {
+	now := time.Now()
+	data, err := w.render(WebhookData{AlertEvent: ev, Time: now})
+	if err != nil {
+		// 模板错误重试也不会成功
+		return Permanent(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, w.Method, w.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", w.ContentType)
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+	if w.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+w.BearerToken)
+	} else if w.Username != "" {
+		req.SetBasicAuth(w.Username, w.Password)
+	}
+	if w.Secret != "" {
+		ts := strconv.FormatInt(now.Unix(), 10)
+		req.Header.Set(w.TimestampHeader, ts)
+		req.Header.Set(w.SignatureHeader, "sha256="+webhookSign(w.Secret, ts, data))
+	}
+
+	client := &http.Client{Timeout: w.Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if w.success(resp.StatusCode) {
+		return nil
+	}
+	if resp.StatusCode < 300 {
+		return Permanent(fmt.Errorf("webhook unexpected status=%d body=%s", resp.StatusCode, string(body)))
+	}
+	return statusError("webhook", resp, body)
+}
+
+func (w *WebhookNotifier) render(data WebhookData) ([]byte, error) {
+	if w.Body == nil {
+		return json.Marshal(map[string]any{
+			"title":   data.Title,
+			"message": data.Text,
+			"ts":      data.Time.Format(time.RFC3339),
+		})
+	}
+	var buf bytes.Buffer
+	if err := w.Body.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("webhook bodyTemplate: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (w *WebhookNotifier) success(code int) bool {
+	if len(w.SuccessCodes) == 0 {
+		return code >= 200 && code < 300
+	}
+	for _, c := range w.SuccessCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookSign 计算 HMAC-SHA256("<时间戳>.<请求体>") 的十六进制结果，接收方按同样方式计算并比对，
+// 同时校验时间戳以防重放。
+func webhookSign(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
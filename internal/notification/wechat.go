@@ -4,31 +4,329 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
+
+	"elasticsearch-alert/internal/config"
+	"elasticsearch-alert/internal/logging"
+)
+
+const (
+	// weChatMaxBytes 群机器人 markdown 消息 content 的上限为 4096 字节
+	weChatMaxBytes = 4096
+	// weChatAppMaxBytes 应用消息 markdown 的 content 上限为 2048 字节
+	weChatAppMaxBytes = 2048
+	weChatAPIURL      = "https://qyapi.weixin.qq.com"
 )
 
+// 企业微信在 HTTP 200 的响应体中通过 errcode 返回的错误码
+const (
+	weChatErrSystemBusy     = -1
+	weChatErrInvalidToken   = 40014
+	weChatErrTokenExpired   = 42001
+	weChatErrRateLimited    = 45009
+	weChatErrInvalidWebhook = 93000
+)
+
+// 企业微信消息类型
+const (
+	WeChatMarkdown     = "markdown"
+	WeChatTemplateCard = "template_card"
+)
+
+// WeChatNotifier 发送企业微信消息，支持 markdown 与 template_card（带跳转按钮的文本通知卡片）。
+// 配置 CorpID 时使用应用消息模式：通过 access_token 调用 message/send 发送给指定成员 / 部门 / 标签，
+// 否则使用群机器人 webhook。
 type WeChatNotifier struct {
-	Webhook string
-	Timeout time.Duration
+	Webhook             string
+	EnableAtAll         bool
+	AtAllSeverities     []string
+	MentionedList       []string
+	MentionedMobileList []string
+	Timeout             time.Duration
+	// MaxBytes 消息正文的大小预算，超出时按优先级收缩内容
+	MaxBytes    int
+	MessageType string
+
+	CorpID  string
+	AgentID int
+	Secret  string
+	ToUser  []string
+	ToParty []string
+	ToTag   []string
+	APIURL  string
+
+	token *accessToken
+}
+
+// NewWeChatNotifier 根据配置创建企业微信渠道
+func NewWeChatNotifier(cfg config.WeChatConfig) (*WeChatNotifier, error) {
+	w := &WeChatNotifier{
+		Webhook:             cfg.Webhook,
+		EnableAtAll:         cfg.EnableAtAll,
+		AtAllSeverities:     cfg.AtAllSeverities,
+		MentionedList:       cfg.MentionedList,
+		MentionedMobileList: cfg.MentionedMobileList,
+		Timeout:             parseDurationDefault(cfg.Timeout, 5*time.Second),
+		MaxBytes:            cfg.MaxBytes,
+		MessageType:         cfg.MessageType,
+		CorpID:              cfg.App.CorpID,
+		AgentID:             cfg.App.AgentID,
+		Secret:              cfg.App.Secret,
+		ToUser:              cfg.App.ToUser,
+		ToParty:             cfg.App.ToParty,
+		ToTag:               cfg.App.ToTag,
+		APIURL:              cfg.App.APIURL,
+	}
+	switch w.MessageType {
+	case "":
+		w.MessageType = WeChatMarkdown
+	case WeChatMarkdown, WeChatTemplateCard:
+	default:
+		return nil, fmt.Errorf("wechat: unknown messageType %q", cfg.MessageType)
+	}
+	w.token = &accessToken{fetch: w.fetchToken}
+	return w, nil
 }
 
 func (w *WeChatNotifier) Name() string { return "wechat" }
 
-func (w *WeChatNotifier) Send(ctx context.Context, title, text string) error {
+func (w *WeChatNotifier) Send(ctx context.Context, ev *AlertEvent) error {
+	msg := w.message(ev)
+	if w.CorpID != "" {
+		return w.sendApp(ctx, ev, msg)
+	}
+	if err := w.postWebhook(ctx, msg); err != nil {
+		return err
+	}
+
+	// markdown / template_card 类型不支持 @ 提醒，需要 @ 时额外发送一条 text 消息。
+	// 告警正文已经送达，提醒消息失败只记录日志，不返回错误，避免重试时正文重复发送
+	mentioned, mobiles := w.mentions(ev)
+	if len(mentioned) == 0 && len(mobiles) == 0 {
+		return nil
+	}
+	err := w.postWebhook(ctx, map[string]any{
+		"msgtype": "text",
+		"text": map[string]any{
+			"content":               mentionText(ev),
+			"mentioned_list":        mentioned,
+			"mentioned_mobile_list": mobiles,
+		},
+	})
+	if err != nil {
+		logging.Errorf("企业微信 @ 提醒消息发送失败: %v", err)
+	}
+	return nil
+}
+
+// Cost 群机器人需要 @ 提醒时一次发送调用两次 webhook，消耗两个限流令牌
+func (w *WeChatNotifier) Cost(ev *AlertEvent) int {
+	if w.CorpID != "" {
+		return 1
+	}
+	if mentioned, mobiles := w.mentions(ev); len(mentioned) > 0 || len(mobiles) > 0 {
+		return 2
+	}
+	return 1
+}
+
+// mentions 返回群机器人需要 @ 的成员 userid 与手机号（含值班人员），恢复 / 确认时不再 @
+func (w *WeChatNotifier) mentions(ev *AlertEvent) ([]string, []string) {
+	if !mentionable(ev) {
+		return nil, nil
+	}
+	var recipientIDs, recipientMobiles []string
+	for _, r := range ev.Recipients {
+		recipientIDs = append(recipientIDs, r.WeChatUserID)
+		recipientMobiles = append(recipientMobiles, r.Phone)
+	}
+	mentioned := mergeUnique(w.MentionedList, ev.Mentions.WeChatUserIDs, recipientIDs)
+	if atAll(w.EnableAtAll, w.AtAllSeverities, ev.Severity) {
+		mentioned = append(mentioned, "@all")
+	}
+	return mentioned, mergeUnique(w.MentionedMobileList, ev.Mentions.WeChatMobiles, recipientMobiles)
+}
+
+// message 按配置的消息类型构造消息体；template_card 需要跳转链接，没有链接时退回 markdown
+func (w *WeChatNotifier) message(ev *AlertEvent) map[string]any {
+	if w.MessageType == WeChatTemplateCard {
+		if card, ok := weChatTemplateCard(ev); ok {
+			return map[string]any{"msgtype": "template_card", "template_card": card}
+		}
+	}
 	// 企业微信使用 Markdown，可以在标题前增加 Emoji 提示
-	content := fmt.Sprintf("**🚨 %s**\n%s", title, text)
-	// 在底部追加 @所有人 提示（企业微信 markdown 类型不支持真正的 mentioned_list，这里仅作视觉提醒）
-	content += "\n\n@所有人"
-	payload := map[string]any{
-		"msgtype": "markdown",
-		"markdown": map[string]string{
-			"content": content,
+	limit := weChatMaxBytes
+	if w.CorpID != "" {
+		limit = weChatAppMaxBytes
+	}
+	header := fmt.Sprintf("**%s %s**\n", statusIcon(ev.Status), ev.Title)
+	content := header + fitEvent(ev, maxBytesDefault(w.MaxBytes, limit)-len(header)).Text
+	return map[string]any{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"content": content},
+	}
+}
+
+// weChatTemplateCard 渲染文本通知模版卡片：命中条数作为关键数据，概览为二级标题列表，
+// 跳转按钮（最多 3 个）依次为详细日志页面、Discover、确认页面与 Runbook。各字段有长度限制，超出部分按字符截断。
+func weChatTemplateCard(ev *AlertEvent) (map[string]any, bool) {
+	sum := ev.Summary
+	var link string
+	for _, l := range []string{sum.DetailURL, sum.DiscoverURL, sum.RunbookURL} {
+		if l != "" {
+			link = l
+			break
+		}
+	}
+	if link == "" {
+		return nil, false
+	}
+
+	card := map[string]any{
+		"card_type": "text_notice",
+		"source":    map[string]any{"desc": "Elasticsearch 日志告警"},
+		"main_title": map[string]any{
+			"title": truncateRunes(statusIcon(ev.Status)+" "+ev.Rule, 26),
+			"desc":  truncateRunes(ev.Severity, 30),
 		},
+		"card_action": map[string]any{"type": 1, "url": link},
+	}
+	if ev.Status == StatusFiring || ev.Status == "" {
+		card["emphasis_content"] = map[string]any{"title": fmt.Sprintf("%d", ev.Count), "desc": "命中条数"}
 	}
-	b, _ := json.Marshal(payload)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.Webhook, bytes.NewReader(b))
+	sub := sum.Description
+	if sum.Log != "" {
+		sub = sum.Log
+	}
+	if sub != "" {
+		card["sub_title_text"] = truncateRunes(sub, 112)
+	}
+
+	var items []map[string]any
+	for _, f := range append(append([]Field{}, sum.Overview...), sum.Target...) {
+		if len(items) == 6 {
+			break
+		}
+		items = append(items, map[string]any{"keyname": truncateRunes(f.Name, 5), "value": truncateRunes(f.Value, 26)})
+	}
+	if len(items) > 0 {
+		card["horizontal_content_list"] = items
+	}
+
+	var jumps []map[string]any
+	if sum.DetailURL != "" {
+		jumps = append(jumps, map[string]any{"type": 1, "title": "查看日志详情", "url": sum.DetailURL})
+	}
+	if sum.DiscoverURL != "" {
+		jumps = append(jumps, map[string]any{"type": 1, "title": "Discover", "url": sum.DiscoverURL})
+	}
+	if sum.AckURL != "" {
+		jumps = append(jumps, map[string]any{"type": 1, "title": "Ack", "url": sum.AckURL})
+	}
+	if sum.RunbookURL != "" {
+		jumps = append(jumps, map[string]any{"type": 1, "title": "Runbook", "url": sum.RunbookURL})
+	}
+	card["jump_list"] = jumps[:min(len(jumps), 3)]
+	return card, true
+}
+
+func (w *WeChatNotifier) postWebhook(ctx context.Context, payload map[string]any) error {
+	return w.post(ctx, w.Webhook, payload, nil)
+}
+
+// sendApp 以应用消息发送给配置的成员 / 部门 / 标签，以及规则 @ 的成员与值班人员
+func (w *WeChatNotifier) sendApp(ctx context.Context, ev *AlertEvent, msg map[string]any) error {
+	var recipientIDs []string
+	for _, r := range ev.Recipients {
+		recipientIDs = append(recipientIDs, r.WeChatUserID)
+	}
+	users := mergeUnique(w.ToUser, ev.Mentions.WeChatUserIDs, recipientIDs)
+	if len(users) == 0 && len(w.ToParty) == 0 && len(w.ToTag) == 0 {
+		return Permanent(fmt.Errorf("wechat app: no receivers"))
+	}
+	msg["agentid"] = w.AgentID
+	msg["touser"] = strings.Join(users, "|")
+	msg["toparty"] = strings.Join(w.ToParty, "|")
+	msg["totag"] = strings.Join(w.ToTag, "|")
+
+	var res struct {
+		InvalidUser  string `json:"invaliduser"`
+		InvalidParty string `json:"invalidparty"`
+		InvalidTag   string `json:"invalidtag"`
+	}
+	for attempt := 0; ; attempt++ {
+		token, err := w.token.Get(ctx)
+		if err != nil {
+			return err
+		}
+		endpoint := w.apiURL() + "/cgi-bin/message/send?access_token=" + url.QueryEscape(token)
+		err = w.post(ctx, endpoint, msg, &res)
+		var we *weChatError
+		if attempt == 0 && errors.As(err, &we) && we.tokenInvalid() {
+			w.token.Invalidate()
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		break
+	}
+	if res.InvalidUser != "" || res.InvalidParty != "" || res.InvalidTag != "" {
+		logging.Errorf("企业微信应用消息部分接收方无效: user=%s party=%s tag=%s", res.InvalidUser, res.InvalidParty, res.InvalidTag)
+	}
+	return nil
+}
+
+func (w *WeChatNotifier) apiURL() string {
+	if w.APIURL != "" {
+		return strings.TrimRight(w.APIURL, "/")
+	}
+	return weChatAPIURL
+}
+
+// fetchToken 获取应用的 access_token
+func (w *WeChatNotifier) fetchToken(ctx context.Context) (string, time.Duration, error) {
+	endpoint := fmt.Sprintf("%s/cgi-bin/gettoken?corpid=%s&corpsecret=%s",
+		w.apiURL(), url.QueryEscape(w.CorpID), url.QueryEscape(w.Secret))
+	var res struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := w.post(ctx, endpoint, nil, &res); err != nil {
+		return "", 0, fmt.Errorf("wechat gettoken: %w", err)
+	}
+	return res.AccessToken, time.Duration(res.ExpiresIn) * time.Second, nil
+}
+
+// weChatError 企业微信在响应体中通过 errcode 返回的错误
+type weChatError struct {
+	Code int
+	Msg  string
+}
+
+func (e *weChatError) Error() string {
+	return fmt.Sprintf("wechat errcode=%d errmsg=%s", e.Code, e.Msg)
+}
+
+func (e *weChatError) tokenInvalid() bool {
+	return e.Code == weChatErrInvalidToken || e.Code == weChatErrTokenExpired
+}
+
+// post 发送请求并检查响应中的 errcode：45009（频率超限）与 -1（系统繁忙）可重试，
+// 93000（webhook key 无效）等其他错误不可重试。payload 为 nil 时发送 GET 请求。
+func (w *WeChatNotifier) post(ctx context.Context, endpoint string, payload map[string]any, out any) error {
+	method, body := http.MethodGet, io.Reader(nil)
+	if payload != nil {
+		b, _ := json.Marshal(payload)
+		method, body = http.MethodPost, bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
 	if err != nil {
 		return err
 	}
@@ -39,8 +337,36 @@ func (w *WeChatNotifier) Send(ctx context.Context, title, text string) error {
 		return err
 	}
 	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("wechat webhook status=%d", resp.StatusCode)
+		return statusError("wechat", resp, data)
+	}
+
+	var res struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return fmt.Errorf("wechat: decode response: %w", err)
+	}
+	if res.ErrCode != 0 {
+		we := &weChatError{Code: res.ErrCode, Msg: res.ErrMsg}
+		switch {
+		case res.ErrCode == weChatErrRateLimited:
+			// 群机器人每分钟最多 20 条，等到下一分钟再重试
+			return Temporary(we, time.Minute)
+		case res.ErrCode == weChatErrSystemBusy || we.tokenInvalid():
+			return Temporary(we, 0)
+		case res.ErrCode == weChatErrInvalidWebhook:
+			return Permanent(fmt.Errorf("%w (check the key in the webhook URL)", we))
+		default:
+			return Permanent(we)
+		}
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("wechat: decode response: %w", err)
+		}
 	}
 	return nil
 }
@@ -0,0 +1,286 @@
+package oncall
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"elasticsearch-alert/internal/config"
+)
+
+// Shift 描述某一时刻某张值班表的当班情况
+type Shift struct {
+	Schedule string              `json:"schedule"`
+	Person   config.OnCallPerson `json:"person"`
+	Layer    string              `json:"layer,omitempty"`
+	Override bool                `json:"override"`
+	Start    time.Time           `json:"start"`
+	End      time.Time           `json:"end"`
+}
+
+// Resolver 根据配置的值班表计算任意时刻的值班人员
+type Resolver struct {
+	people    map[string]config.OnCallPerson
+	schedules map[string]*schedule
+	names     []string
+}
+
+type schedule struct {
+	name      string
+	loc       *time.Location
+	channels  []string
+	layers    []*layer
+	overrides []override
+}
+
+type layer struct {
+	name         string
+	anchor       time.Time
+	days         int           // 按自然日轮换（daily/weekly），避免夏令时带来的偏移
+	period       time.Duration // 按固定时长轮换
+	participants []string
+	activeFrom   int // 每日生效起点（距 0 点的分钟数），-1 表示全天
+	activeTo     int
+	weekdays     map[time.Weekday]bool
+}
+
+type override struct {
+	person string
+	start  time.Time
+	end    time.Time
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// New 解析值班配置。defaultLoc 用于未单独配置时区的值班表。
+func New(cfg config.OnCallConfig, defaultLoc *time.Location) (*Resolver, error) {
+	r := &Resolver{
+		people:    make(map[string]config.OnCallPerson),
+		schedules: make(map[string]*schedule),
+	}
+	for _, p := range cfg.People {
+		if p.ID == "" {
+			return nil, fmt.Errorf("oncall person %q: id required", p.Name)
+		}
+		if p.Name == "" {
+			p.Name = p.ID
+		}
+		r.people[p.ID] = p
+	}
+	for _, sc := range cfg.Schedules {
+		s, err := r.parseSchedule(sc, defaultLoc)
+		if err != nil {
+			return nil, fmt.Errorf("oncall schedule %q: %w", sc.Name, err)
+		}
+		if _, dup := r.schedules[s.name]; dup {
+			return nil, fmt.Errorf("oncall schedule %q: duplicated name", sc.Name)
+		}
+		r.schedules[s.name] = s
+		r.names = append(r.names, s.name)
+	}
+	return r, nil
+}
+
+func (r *Resolver) parseSchedule(sc config.ScheduleConfig, defaultLoc *time.Location) (*schedule, error) {
+	if sc.Name == "" {
+		return nil, fmt.Errorf("name required")
+	}
+	loc := defaultLoc
+	if sc.Timezone != "" {
+		l, err := time.LoadLocation(sc.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("timezone: %w", err)
+		}
+		loc = l
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	s := &schedule{name: sc.Name, loc: loc, channels: sc.Channels}
+	if len(s.channels) == 0 {
+		s.channels = []string{"email"}
+	}
+	for i, lc := range sc.Layers {
+		l, err := r.parseLayer(lc, loc)
+		if err != nil {
+			return nil, fmt.Errorf("layer %d: %w", i, err)
+		}
+		s.layers = append(s.layers, l)
+	}
+	for i, oc := range sc.Overrides {
+		if _, ok := r.people[oc.Person]; !ok {
+			return nil, fmt.Errorf("override %d: unknown person %q", i, oc.Person)
+		}
+		start, err := time.ParseInLocation("2006-01-02 15:04", oc.Start, loc)
+		if err != nil {
+			return nil, fmt.Errorf("override %d start: %w", i, err)
+		}
+		end, err := time.ParseInLocation("2006-01-02 15:04", oc.End, loc)
+		if err != nil {
+			return nil, fmt.Errorf("override %d end: %w", i, err)
+		}
+		if !end.After(start) {
+			return nil, fmt.Errorf("override %d: end must be after start", i)
+		}
+		s.overrides = append(s.overrides, override{person: oc.Person, start: start, end: end})
+	}
+	return s, nil
+}
+
+func (r *Resolver) parseLayer(lc config.ScheduleLayerConfig, loc *time.Location) (*layer, error) {
+	if len(lc.Participants) == 0 {
+		return nil, fmt.Errorf("participants required")
+	}
+	for _, id := range lc.Participants {
+		if _, ok := r.people[id]; !ok {
+			return nil, fmt.Errorf("unknown person %q", id)
+		}
+	}
+	handoff := lc.HandoffTime
+	if handoff == "" {
+		handoff = "09:00"
+	}
+	anchor, err := time.ParseInLocation("2006-01-02 15:04", lc.Start+" "+handoff, loc)
+	if err != nil {
+		return nil, fmt.Errorf("start/handoffTime: %w", err)
+	}
+	l := &layer{name: lc.Name, anchor: anchor, participants: lc.Participants, activeFrom: -1}
+	switch strings.ToLower(lc.Rotation) {
+	case "", "weekly":
+		l.days = 7
+	case "daily":
+		l.days = 1
+	default:
+		d, err := time.ParseDuration(lc.Rotation)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid rotation %q", lc.Rotation)
+		}
+		l.period = d
+	}
+	if lc.ActiveHours != "" {
+		from, to, ok := strings.Cut(lc.ActiveHours, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid activeHours %q", lc.ActiveHours)
+		}
+		if l.activeFrom, err = parseClock(from); err != nil {
+			return nil, err
+		}
+		if l.activeTo, err = parseClock(to); err != nil {
+			return nil, err
+		}
+	}
+	if len(lc.Weekdays) > 0 {
+		l.weekdays = make(map[time.Weekday]bool)
+		for _, d := range lc.Weekdays {
+			wd, ok := weekdayNames[strings.ToLower(d)[:min(3, len(d))]]
+			if !ok {
+				return nil, fmt.Errorf("invalid weekday %q", d)
+			}
+			l.weekdays[wd] = true
+		}
+	}
+	return l, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid clock %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Names 返回所有值班表名称（按配置顺序）
+func (r *Resolver) Names() []string {
+	return r.names
+}
+
+// Channels 返回通知某张值班表当前值班人员时使用的渠道
+func (r *Resolver) Channels(name string) []string {
+	if s, ok := r.schedules[name]; ok {
+		return s.channels
+	}
+	return nil
+}
+
+// Current 返回值班表在 t 时刻的值班情况：临时替班优先，其次是排在最后且当前生效的轮换层
+func (r *Resolver) Current(name string, t time.Time) (Shift, error) {
+	s, ok := r.schedules[name]
+	if !ok {
+		return Shift{}, fmt.Errorf("oncall schedule %q not found", name)
+	}
+	t = t.In(s.loc)
+	for i := len(s.overrides) - 1; i >= 0; i-- {
+		o := s.overrides[i]
+		if !t.Before(o.start) && t.Before(o.end) {
+			return Shift{Schedule: name, Person: r.people[o.person], Override: true, Start: o.start, End: o.end}, nil
+		}
+	}
+	for i := len(s.layers) - 1; i >= 0; i-- {
+		l := s.layers[i]
+		if !l.active(t) {
+			continue
+		}
+		idx, start, end := l.shift(t)
+		return Shift{Schedule: name, Person: r.people[l.participants[idx]], Layer: l.name, Start: start, End: end}, nil
+	}
+	return Shift{}, fmt.Errorf("oncall schedule %q: nobody on call at %s", name, t.Format("2006-01-02 15:04"))
+}
+
+// Now 返回所有值班表在 t 时刻的值班情况，无人值班的表会被跳过
+func (r *Resolver) Now(t time.Time) []Shift {
+	shifts := make([]Shift, 0, len(r.names))
+	for _, name := range r.names {
+		if sh, err := r.Current(name, t); err == nil {
+			shifts = append(shifts, sh)
+		}
+	}
+	sort.SliceStable(shifts, func(i, j int) bool { return shifts[i].Schedule < shifts[j].Schedule })
+	return shifts
+}
+
+func (l *layer) active(t time.Time) bool {
+	if t.Before(l.anchor) {
+		return false
+	}
+	if l.weekdays != nil && !l.weekdays[t.Weekday()] {
+		return false
+	}
+	if l.activeFrom < 0 {
+		return true
+	}
+	m := t.Hour()*60 + t.Minute()
+	if l.activeFrom <= l.activeTo {
+		return m >= l.activeFrom && m < l.activeTo
+	}
+	// 跨零点的时间段，如 "22:00-06:00"
+	return m >= l.activeFrom || m < l.activeTo
+}
+
+// periodStart 返回第 k 个轮换周期的起始时间
+func (l *layer) periodStart(k int) time.Time {
+	if l.days > 0 {
+		return l.anchor.AddDate(0, 0, k*l.days)
+	}
+	return l.anchor.Add(time.Duration(k) * l.period)
+}
+
+// shift 返回 t 所在轮换周期的值班人员下标与起止时间
+func (l *layer) shift(t time.Time) (int, time.Time, time.Time) {
+	approx := l.period
+	if l.days > 0 {
+		approx = time.Duration(l.days) * 24 * time.Hour
+	}
+	k := int(t.Sub(l.anchor) / approx)
+	for k > 0 && l.periodStart(k).After(t) {
+		k--
+	}
+	for !l.periodStart(k + 1).After(t) {
+		k++
+	}
+	return k % len(l.participants), l.periodStart(k), l.periodStart(k + 1)
+}
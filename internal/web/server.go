@@ -1,26 +1,39 @@
 package web
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"html/template"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"elasticsearch-alert/internal/alert"
 	"elasticsearch-alert/internal/config"
 	eswrap "elasticsearch-alert/internal/elasticsearch"
 	"elasticsearch-alert/internal/logging"
+	"elasticsearch-alert/internal/notification"
+	"elasticsearch-alert/internal/oncall"
 )
 
 // Server 提供一个简单的只读 Web 页面，用于查看告警命中的单条日志详情。
 type Server struct {
-	cfg *config.Config
-	es  *eswrap.Client
+	cfg        *config.Config
+	es         *eswrap.Client
+	engine     *alert.Engine
+	oncall     *oncall.Resolver
+	dispatcher *notification.Dispatcher
 }
 
-func NewServer(cfg *config.Config, es *eswrap.Client) *Server {
+func NewServer(cfg *config.Config, es *eswrap.Client, engine *alert.Engine, schedules *oncall.Resolver, dispatcher *notification.Dispatcher) *Server {
 	return &Server{
-		cfg: cfg,
-		es:  es,
+		cfg:        cfg,
+		es:         es,
+		engine:     engine,
+		oncall:     schedules,
+		dispatcher: dispatcher,
 	}
 }
 
@@ -35,6 +48,14 @@ func (s *Server) Start() error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/healthz", s.handleHealth)
 	mux.HandleFunc("/logs", s.handleLogDetail)
+	mux.HandleFunc("/oncall", s.handleOnCall)
+	mux.HandleFunc("/ack", s.handleAckPage)
+	mux.HandleFunc("/api/alerts/ack", s.handleAck)
+	mux.HandleFunc("/api/history", s.handleHistory)
+	mux.HandleFunc("/api/deadletters", s.handleDeadLetters)
+	mux.HandleFunc("/api/deadletters/retry", s.handleDeadLetterRetry)
+	mux.HandleFunc("/api/notifications/stats", s.handleNotificationStats)
+	mux.HandleFunc("/metrics", s.handleMetrics)
 
 	addr := s.cfg.Web.Listen
 	if addr == "" {
@@ -49,6 +70,229 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write([]byte(`{"status":"ok"}`))
 }
 
+// handleOnCall 返回当前值班人员，可通过 ?schedule=<名称> 只查询某一张值班表；
+// 不带 Authorization: Bearer <web.ackSecret> 时隐藏邮箱、手机号等联系方式
+func (s *Server) handleOnCall(w http.ResponseWriter, r *http.Request) {
+	shifts := []oncall.Shift{}
+	if s.oncall != nil {
+		now := time.Now()
+		if name := r.URL.Query().Get("schedule"); name != "" {
+			sh, err := s.oncall.Current(name, now)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			shifts = append(shifts, sh)
+		} else {
+			shifts = s.oncall.Now(now)
+		}
+	}
+	// 联系方式只返回给携带 Bearer Token 的请求，其他请求只能看到值班人员的姓名
+	if !s.ackTokenValid(r) {
+		for i := range shifts {
+			p := shifts[i].Person
+			shifts[i].Person = config.OnCallPerson{ID: p.ID, Name: p.Name}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(shifts)
+}
+
+// handleAck 确认规则当前进行中的告警：POST /api/alerts/ack?rule=<规则名称>&by=<确认人>。
+// 需要 Authorization: Bearer <web.ackSecret>，或携带通知中确认链接的签名参数（fp、exp、sig）
+func (s *Server) handleAck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "仅支持 POST", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	rule := q.Get("rule")
+	if rule == "" {
+		http.Error(w, "缺少 rule 参数", http.StatusBadRequest)
+		return
+	}
+	if !s.ackTokenValid(r) {
+		if err := s.engine.VerifyAckLink(rule, q.Get("fp"), q.Get("exp"), q.Get("sig")); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+	if err := s.engine.Ack(rule, q.Get("by")); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{"status":"acked"}`))
+}
+
+// ackTokenValid 检查请求是否携带 Authorization: Bearer <web.ackSecret>，未配置 ackSecret 时总是 false
+func (s *Server) ackTokenValid(r *http.Request) bool {
+	secret := s.cfg.Web.AckSecret
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return secret != "" && ok && subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+}
+
+// handleAckPage 是通知中“Ack”按钮指向的确认页面：钉钉 / 企业微信等按钮只能以 GET 打开链接，
+// 因此 GET 只展示确认表单（避免链接预览误触发确认），提交表单（POST）后才确认告警。
+// 链接中的签名（fp、exp、sig）随表单一起提交，GET 与 POST 都会校验，其他页面无法伪造确认请求。
+func (s *Server) handleAckPage(w http.ResponseWriter, r *http.Request) {
+	data := struct {
+		Title       string
+		Rule        string
+		Fingerprint string
+		Expires     string
+		Signature   string
+		By          string
+		Message     string
+		Acked       bool
+	}{Title: "确认 Elasticsearch 日志告警"}
+
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		data.Rule, data.Fingerprint, data.Expires, data.Signature = q.Get("rule"), q.Get("fp"), q.Get("exp"), q.Get("sig")
+	case http.MethodPost:
+		data.Rule, data.Fingerprint, data.Expires, data.Signature = r.FormValue("rule"), r.FormValue("fp"), r.FormValue("exp"), r.FormValue("sig")
+		data.By = r.FormValue("by")
+	default:
+		http.Error(w, "仅支持 GET / POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if data.Rule == "" {
+		http.Error(w, "缺少 rule 参数", http.StatusBadRequest)
+		return
+	}
+	if err := s.engine.VerifyAckLink(data.Rule, data.Fingerprint, data.Expires, data.Signature); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if r.Method == http.MethodPost {
+		if err := s.engine.Ack(data.Rule, data.By); err != nil {
+			data.Message = err.Error()
+		} else {
+			data.Acked = true
+			data.Message = "告警已确认，后续通知将标记确认人并停止重复提醒"
+		}
+	}
+
+	tmpl := template.Must(template.New("ack").Parse(ackHTML))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(w, data); err != nil {
+		logging.Errorf("渲染告警确认页面失败: %v", err)
+	}
+}
+
+const ackHTML = `
+<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+  <meta charset="UTF-8">
+  <title>{{.Title}}</title>
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <style>
+    body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, Helvetica, Arial, sans-serif; margin: 0; background-color: #f5f5f7; color: #27272a; }
+    .card { max-width: 480px; margin: 48px auto; background: #ffffff; border-radius: 12px; border: 1px solid rgba(148,163,184,0.4); box-shadow: 0 10px 30px rgba(15,23,42,0.08); padding: 24px; }
+    h1 { font-size: 18px; margin: 0 0 16px; }
+    .rule { font-family: Menlo, Monaco, Consolas, monospace; background: #f4f4f5; padding: 2px 6px; border-radius: 4px; }
+    input[type=text] { width: 100%; box-sizing: border-box; padding: 8px 10px; margin: 12px 0; border: 1px solid #d4d4d8; border-radius: 6px; font-size: 14px; }
+    button { width: 100%; padding: 10px; border: none; border-radius: 6px; background: #f97316; color: #ffffff; font-size: 15px; cursor: pointer; }
+    .message { margin-top: 16px; padding: 10px 12px; border-radius: 6px; background: #fee2e2; }
+    .message.ok { background: #dcfce7; }
+  </style>
+</head>
+<body>
+  <div class="card">
+    <h1>👀 {{.Title}}</h1>
+    <div>规则：<span class="rule">{{.Rule}}</span></div>
+    {{if not .Acked}}
+    <form method="post" action="ack">
+      <input type="hidden" name="rule" value="{{.Rule}}">
+      <input type="hidden" name="fp" value="{{.Fingerprint}}">
+      <input type="hidden" name="exp" value="{{.Expires}}">
+      <input type="hidden" name="sig" value="{{.Signature}}">
+      <input type="text" name="by" value="{{.By}}" placeholder="确认人（姓名或工号）">
+      <button type="submit">确认告警</button>
+    </form>
+    {{end}}
+    {{if .Message}}<div class="message{{if .Acked}} ok{{end}}">{{.Message}}</div>{{end}}
+  </div>
+</body>
+</html>
+`
+
+// handleHistory 返回最近的发送历史（含 exec 命令输出），支持 ?rule=<规则名称>&limit=<条数>
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 100
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.dispatcher.History().List(r.URL.Query().Get("rule"), limit))
+}
+
+// handleDeadLetters 返回多次重试仍发送失败的通知
+func (s *Server) handleDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if !s.ackTokenValid(r) {
+		http.Error(w, "需要 Authorization: Bearer <web.ackSecret>", http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.dispatcher.DeadLetters())
+}
+
+// handleDeadLetterRetry 将指定死信重新放回发送队列：POST /api/deadletters/retry?id=<id>
+func (s *Server) handleDeadLetterRetry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "仅支持 POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.ackTokenValid(r) {
+		http.Error(w, "需要 Authorization: Bearer <web.ackSecret>", http.StatusUnauthorized)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "缺少 id 参数", http.StatusBadRequest)
+		return
+	}
+	if err := s.dispatcher.Retry(id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{"status":"queued"}`))
+}
+
+// handleNotificationStats 返回各渠道的发送 / 限流 / 合并 / 死信统计
+func (s *Server) handleNotificationStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.dispatcher.Stats())
+}
+
+// handleMetrics 以 Prometheus 文本格式导出通知发送统计
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	stats := s.dispatcher.Stats()
+	metrics := []struct {
+		name, help, typ string
+		value           func(st notification.ChannelStats) float64
+	}{
+		{"elasticsearch_alert_notifications_queued", "当前排队中的通知条数", "gauge", func(st notification.ChannelStats) float64 { return float64(st.Queued) }},
+		{"elasticsearch_alert_notifications_enqueued_total", "进入发送队列的通知条数", "counter", func(st notification.ChannelStats) float64 { return float64(st.Enqueued) }},
+		{"elasticsearch_alert_notifications_sent_total", "发送成功的通知条数", "counter", func(st notification.ChannelStats) float64 { return float64(st.Sent) }},
+		{"elasticsearch_alert_notifications_failed_attempts_total", "失败的发送尝试次数", "counter", func(st notification.ChannelStats) float64 { return float64(st.Failed) }},
+		{"elasticsearch_alert_notifications_dead_lettered_total", "转入死信的通知条数", "counter", func(st notification.ChannelStats) float64 { return float64(st.DeadLettered) }},
+		{"elasticsearch_alert_notifications_rate_limited_total", "因限流而等待的次数", "counter", func(st notification.ChannelStats) float64 { return float64(st.RateLimited) }},
+		{"elasticsearch_alert_notifications_suppressed_total", "因限流被合并进汇总消息的告警条数", "counter", func(st notification.ChannelStats) float64 { return float64(st.Suppressed) }},
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, m := range metrics {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ)
+		for _, st := range stats {
+			fmt.Fprintf(w, "%s{channel=%q} %g\n", m.name, st.Channel, m.value(st))
+		}
+	}
+}
+
 func (s *Server) handleLogDetail(w http.ResponseWriter, r *http.Request) {
 	index := r.URL.Query().Get("index")
 	id := r.URL.Query().Get("id")
@@ -99,7 +343,7 @@ func (s *Server) handleLogDetail(w http.ResponseWriter, r *http.Request) {
 		Title:  "Elasticsearch 日志告警详情",
 	}
 
-	if ts, ok := doc.Source["@timestamp"].(string); ok {
+	if ts, ok := doc.Source[s.cfg.Rules.GetTimestampField()].(string); ok {
 		data.Timestamp = ts
 	}
 	if ns, ok := doc.Source["kubernetes_namespace_name"].(string); ok {